@@ -0,0 +1,220 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// EventSchemaVersion is the version of the Event JSON schema. It is bumped whenever
+// a field is removed or changes meaning; new optional fields do not change it.
+const EventSchemaVersion = 1
+
+// Event types published to a Locker's Audit publisher.
+const (
+	EventAcquire  = "acquire"  // The key was not locked and is now held by NodeID.
+	EventRenew    = "renew"    // NodeID already held the key and moved its expiration.
+	EventTakeover = "takeover" // The key's lock had expired for PrevNodeID and is now held by NodeID.
+	EventDeny     = "deny"     // NodeID failed to lock the key because another node holds it.
+	EventRelease  = "release"  // NodeID unlocked the key.
+)
+
+// Event describes a single lock lifecycle change.
+//
+// Events are delta-encoded: the Prev fields are only set when the operation replaced or
+// removed an existing lock entry, and only hold the values that were overwritten.
+// Times are milliseconds since the Unix epoch, the same unit stored in the lock table.
+//
+// JSON schema (version 1):
+//
+//	{
+//	  "version": 1,                 // EventSchemaVersion
+//	  "type": "acquire",            // acquire | renew | takeover | deny | release
+//	  "table": "locks",             // Dynamo table name
+//	  "key": "event123",            // lock key
+//	  "node_id": "worker84",        // node performing the operation
+//	  "time": 1620000000000,        // when the operation completed
+//	  "expiration": 1620000060000,  // new lease expiration, omitted for deny and release
+//	  "prev_node_id": "worker12",   // previous holder, omitted when unchanged or unknown
+//	  "prev_expiration": 1619999990000 // previous lease expiration, omitted when unknown
+//	}
+type Event struct {
+	Version        int    `json:"version"`
+	Type           string `json:"type"`
+	Table          string `json:"table"`
+	Key            string `json:"key"`
+	NodeID         string `json:"node_id"`
+	Time           int64  `json:"time"`
+	Expiration     int64  `json:"expiration,omitempty"`
+	PrevNodeID     string `json:"prev_node_id,omitempty"`
+	PrevExpiration int64  `json:"prev_expiration,omitempty"`
+}
+
+// Publisher receives lock lifecycle events. Publishing is best effort; a Publisher
+// error never changes the outcome of the Lock or Unlock call that produced the event.
+//
+// Publish is called synchronously by Lock and Unlock with the caller's context, so its latency,
+// including any SDK retries, is added to every lock operation. Wrap publishers that make network
+// calls, such as KinesisPublisher and FirehosePublisher, in an AsyncPublisher.
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// KinesisPublisher writes events as newline terminated JSON records to a Kinesis stream.
+// The lock key is used as the partition key so events for a key stay ordered.
+type KinesisPublisher struct {
+	Client     *kinesis.Kinesis
+	StreamName string
+}
+
+// Publish implements Publisher.
+func (p *KinesisPublisher) Publish(ctx context.Context, e *Event) error {
+	data, err := marshalEvent(e)
+	if err != nil {
+		return err
+	}
+	_, err = p.Client.PutRecordWithContext(ctx, &kinesis.PutRecordInput{
+		Data:         data,
+		PartitionKey: aws.String(e.Key),
+		StreamName:   aws.String(p.StreamName),
+	})
+	return err
+}
+
+// FirehosePublisher writes events as newline terminated JSON records to a Firehose delivery stream.
+type FirehosePublisher struct {
+	Client             *firehose.Firehose
+	DeliveryStreamName string
+}
+
+// Publish implements Publisher.
+func (p *FirehosePublisher) Publish(ctx context.Context, e *Event) error {
+	data, err := marshalEvent(e)
+	if err != nil {
+		return err
+	}
+	_, err = p.Client.PutRecordWithContext(ctx, &firehose.PutRecordInput{
+		DeliveryStreamName: aws.String(p.DeliveryStreamName),
+		Record:             &firehose.Record{Data: data},
+	})
+	return err
+}
+
+// DefaultAsyncBuffer is the number of events an AsyncPublisher buffers by default.
+const DefaultAsyncBuffer = 1024
+
+// ErrEventDropped is returned by AsyncPublisher.Publish when its buffer is full or it is closed.
+var ErrEventDropped = errors.New("lock event dropped")
+
+// AsyncPublisher forwards events to another Publisher from a background goroutine so publishing
+// adds no latency to Lock and Unlock. Events that don't fit in the buffer are dropped.
+// Close flushes the buffered events.
+//
+// OnError is called from the background goroutine for events the Publisher failed to publish
+// and from the goroutine calling Publish for dropped events, so it must be safe for concurrent use.
+//
+//	pub := &lock.AsyncPublisher{Publisher: &lock.KinesisPublisher{Client: client, StreamName: "lock-events"}}
+//	defer pub.Close()
+//	locker.Audit = pub
+type AsyncPublisher struct {
+	Publisher Publisher
+	Buffer    int                 // Maximum number of pending events. Defaults to DefaultAsyncBuffer
+	OnError   func(*Event, error) // Optional, called with events that failed to publish or were dropped
+	init      sync.Once
+	mu        sync.RWMutex
+	closed    bool
+	events    chan *Event
+	done      chan struct{}
+}
+
+// Publish implements Publisher. It queues e and returns immediately.
+func (p *AsyncPublisher) Publish(ctx context.Context, e *Event) error {
+	p.init.Do(p.start)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.closed {
+		select {
+		case p.events <- e:
+			return nil
+		default:
+		}
+	}
+	p.fail(e, ErrEventDropped)
+	return ErrEventDropped
+}
+
+// Close publishes the buffered events and stops the background goroutine. Events published
+// afterwards are dropped.
+func (p *AsyncPublisher) Close() {
+	p.init.Do(p.start)
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
+
+func (p *AsyncPublisher) start() {
+	size := p.Buffer
+	if size <= 0 {
+		size = DefaultAsyncBuffer
+	}
+	p.events = make(chan *Event, size)
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		for e := range p.events {
+			// The lock operation that produced e may have finished and cancelled its context
+			if err := p.Publisher.Publish(context.Background(), e); err != nil {
+				p.fail(e, err)
+			}
+		}
+	}()
+}
+
+func (p *AsyncPublisher) fail(e *Event, err error) {
+	if p.OnError != nil {
+		p.OnError(e, err)
+	}
+}
+
+func marshalEvent(e *Event) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// publish sends an event to the configured publisher, if any.
+// old is the lock entry that was replaced or removed by the operation.
+func (l *Locker) publish(ctx context.Context, typ, key string, now, expiration int64, old map[string]*dynamodb.AttributeValue) {
+	if l.state.audit == nil {
+		return
+	}
+	e := &Event{
+		Version:    EventSchemaVersion,
+		Type:       typ,
+		Table:      l.state.tableName,
+		Key:        key,
+		NodeID:     l.state.nodeID,
+		Time:       now,
+		Expiration: expiration,
+	}
+	if prev, ok := old["nodeId"]; ok && prev.S != nil && *prev.S != l.state.nodeID {
+		e.PrevNodeID = *prev.S
+	}
+	if prev, ok := old[expColumnName]; ok && prev.N != nil {
+		e.PrevExpiration, _ = strconv.ParseInt(*prev.N, 10, 64)
+	}
+	l.state.audit.Publish(ctx, e)
+}
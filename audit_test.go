@@ -0,0 +1,182 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+type testPublisher struct {
+	events []*Event
+}
+
+func (p *testPublisher) Publish(ctx context.Context, e *Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestAuditTakeover(t *testing.T) {
+	lk, ts := getTestLock(200, `{"Attributes":{"nodeId":{"S":"otherNode"},"lease_expiration":{"N":"1000"}}}`)
+	defer ts.Close()
+	pub := &testPublisher{}
+	lk.Audit = pub
+
+	exp := time.Now().Add(10 * time.Minute)
+	locked, err := lk.Lock(context.Background(), "mylock", exp)
+	if err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	if len(pub.events) != 1 {
+		t.Fatalf("Expected 1 event got %d", len(pub.events))
+	}
+	e := pub.events[0]
+	if e.Type != EventTakeover || e.Key != "mylock" || e.NodeID != "testNode12" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e.PrevNodeID != "otherNode" || e.PrevExpiration != 1000 {
+		t.Errorf("Expected previous holder in event %+v", e)
+	}
+	if e.Expiration != exp.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("Expected expiration %d got %d", exp.UnixNano()/int64(time.Millisecond), e.Expiration)
+	}
+}
+
+func TestAuditTakeoverClaims(t *testing.T) {
+	attempts := 0
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts++; attempts {
+		case 1:
+			// Not renewed, the lock is held by another node
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+			return
+		case 2:
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",`+
+				`"Message":"Transaction cancelled","CancellationReasons":[{"Code":"ConditionalCheckFailed",`+
+				`"Item":{"nodeId":{"S":"otherNode"},"lease_expiration":{"N":"1000"}}},{"Code":"None"}]}`)
+			return
+		}
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	pub := &testPublisher{}
+	lk.Audit = pub
+	lk.Claims = true
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	if len(pub.events) != 1 {
+		t.Fatalf("Expected 1 event got %d", len(pub.events))
+	}
+	if e := pub.events[0]; e.Type != EventTakeover || e.PrevNodeID != "otherNode" || e.PrevExpiration != 1000 {
+		t.Errorf("Expected a takeover from the previous holder got %+v", e)
+	}
+}
+
+func TestAuditDeny(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+	defer ts.Close()
+	pub := &testPublisher{}
+	lk.Audit = pub
+
+	lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if len(pub.events) != 1 || pub.events[0].Type != EventDeny {
+		t.Fatalf("Expected a single deny event got %+v", pub.events)
+	}
+}
+
+func TestAuditRelease(t *testing.T) {
+	lk, ts := getTestLock(200, `{"Attributes":{"nodeId":{"S":"testNode12"},"lease_expiration":{"N":"1000"}}}`)
+	defer ts.Close()
+	pub := &testPublisher{}
+	lk.Audit = pub
+
+	if err := lk.Unlock(context.Background(), "mylock"); err != nil {
+		t.Fatal(err)
+	}
+	if len(pub.events) != 1 {
+		t.Fatalf("Expected 1 event got %d", len(pub.events))
+	}
+	if e := pub.events[0]; e.Type != EventRelease || e.PrevNodeID != "" || e.PrevExpiration != 1000 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+}
+
+func TestKinesisPublisher(t *testing.T) {
+	ts, client := getHTTPResponse(200, `{"SequenceNumber":"1","ShardId":"shardId-000000000000"}`)
+	defer ts.Close()
+	conf := &aws.Config{
+		Endpoint:   &ts.URL,
+		HTTPClient: client,
+		MaxRetries: aws.Int(0),
+	}
+	pub := &KinesisPublisher{
+		Client:     kinesis.New(session.New(), conf.WithRegion("us-west-2")),
+		StreamName: "lock-events",
+	}
+	err := pub.Publish(context.Background(), &Event{Version: EventSchemaVersion, Type: EventAcquire, Key: "mylock"})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+type blockingPublisher struct {
+	release chan struct{}
+	events  chan *Event
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, e *Event) error {
+	<-p.release
+	p.events <- e
+	return nil
+}
+
+func TestAsyncPublisher(t *testing.T) {
+	slow := &blockingPublisher{release: make(chan struct{}), events: make(chan *Event, 3)}
+	var dropped []*Event
+	pub := &AsyncPublisher{
+		Publisher: slow,
+		Buffer:    1,
+		OnError: func(e *Event, err error) {
+			if errors.Is(err, ErrEventDropped) {
+				dropped = append(dropped, e)
+			}
+		},
+	}
+	ctx := context.Background()
+
+	// The first event is taken by the background goroutine, the second fills the buffer
+	pub.Publish(ctx, &Event{Key: "a"})
+	for len(pub.events) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := pub.Publish(ctx, &Event{Key: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish(ctx, &Event{Key: "c"}); err != ErrEventDropped {
+		t.Errorf("Expected a full buffer to drop events got %v", err)
+	}
+	if len(dropped) != 1 || dropped[0].Key != "c" {
+		t.Errorf("Expected the dropped event to be reported got %v", dropped)
+	}
+
+	close(slow.release)
+	pub.Close()
+	if n := len(slow.events); n != 2 {
+		t.Errorf("Expected Close to flush the buffered events, %d published", n)
+	}
+	if err := pub.Publish(ctx, &Event{Key: "d"}); err != ErrEventDropped {
+		t.Errorf("Expected events published after Close to be dropped got %v", err)
+	}
+}
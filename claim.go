@@ -0,0 +1,176 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	claimKeyPrefix = "_claim:"          // Key prefix of the next owner claim items
+	claimExpColumn = "claim_expiration" // Expiration attribute of the claim items
+)
+
+// errClaimed signals that a lock could not be acquired because another node holds a claim on it.
+var errClaimed = errors.New("claimed by another node")
+
+// Claim registers this node as the next owner of key until expiration, e.g. for a warm standby
+// that should take over deterministically when the current holder fails. Claim returns false
+// if another node already holds an unexpired claim on key.
+//
+// Claims are stored in "_claim:<key>" items in the lock table and are only honored by Lockers
+// with Claims enabled: while a claim is unexpired only the claimant can lock key once the current
+// holder unlocks it or lets it expire. The claim is removed when the claimant acquires the lock.
+// The current holder can keep renewing its lock regardless of claims, but once its lock has
+// expired the claim is honored against it too.
+func (l *Locker) Claim(ctx context.Context, key string, expiration time.Time) (claimed bool, e error) {
+	l.init.Do(l.getState)
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	if err := l.validateSchema(ctx, true); err != nil {
+		return false, err
+	}
+	item := map[string]*dynamodb.AttributeValue{}
+	item[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(claimKeyPrefix + key)}
+	item["nodeId"] = &dynamodb.AttributeValue{S: aws.String(l.state.nodeID)}
+	item[claimExpColumn] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(millis(expiration), 10))}
+	req := &dynamodb.PutItemInput{
+		Item:                      item,
+		ConditionExpression:       aws.String(l.claimCondition()),
+		ExpressionAttributeNames:  map[string]*string{"#key": aws.String(l.state.tableKey)},
+		ExpressionAttributeValues: l.claimValues(),
+		TableName:                 aws.String(l.state.tableName),
+	}
+	_, err := l.state.db.PutItemWithContext(ctx, req, l.requestOptions(ctx)...)
+	if err != nil {
+		if awserr, ok := err.(awserr.Error); ok {
+			if awserr.Code() == "ConditionalCheckFailedException" {
+				// Claimed by someone else
+				return false, nil
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// acquireClaimed locks key for req's item if no other node holds a claim on key, removing any
+// claim in the same transaction. If create is true it first tries to write the item where key is
+// not locked. Otherwise, or if that fails, it replaces the lock item found, or the given held
+// item, if it has expired and, unless it belongs to this node, satisfies takeover. It returns
+// the lock item it replaced, if any, false if the lock is held, and errClaimed if another node's
+// claim prevents acquiring it.
+func (l *Locker) acquireClaimed(ctx context.Context, key string, req *dynamodb.PutItemInput, create bool, held map[string]*dynamodb.AttributeValue, takeover string) (bool, map[string]*dynamodb.AttributeValue, error) {
+	if create {
+		cond := fmt.Sprintf("attribute_not_exists(%s)", l.state.tableKey)
+		acquired, cur, err := l.putClaimed(ctx, key, req.Item, cond, nil, nil)
+		if acquired || err != nil {
+			return acquired, nil, err
+		}
+		held = cur
+	}
+	if held == nil {
+		return false, nil, nil
+	}
+	exp, ok := held[expColumnName]
+	if ms, err := numberAttr(held, expColumnName); !ok || err != nil || ms >= millis(time.Now()) {
+		// Not expired, or Lock's condition can't compare against its expiration
+		return false, nil, nil
+	}
+	if stringAttr(held, "nodeId") == l.state.nodeID {
+		// The AttributeCondition only applies to taking over from other nodes
+		takeover = fmt.Sprintf(":now > %s", expColumnName)
+	}
+	// Only replace the item that was found so the event reports its previous holder
+	values := map[string]*dynamodb.AttributeValue{
+		":prevNodeId":     held["nodeId"],
+		":prevExpiration": exp,
+	}
+	for k, v := range req.ExpressionAttributeValues {
+		values[k] = v
+	}
+	cond := fmt.Sprintf("(%s) AND nodeId = :prevNodeId AND %s = :prevExpiration", takeover, expColumnName)
+	acquired, _, err := l.putClaimed(ctx, key, req.Item, cond, req.ExpressionAttributeNames, values)
+	if !acquired {
+		return false, nil, err
+	}
+	return true, held, nil
+}
+
+// putClaimed writes item if it satisfies cond and no other node holds a claim on key, removing
+// any claim in the same transaction. Only the names and values cond uses are sent. It returns
+// false and the current lock item if cond fails and errClaimed if another node's claim prevents
+// writing it.
+func (l *Locker) putClaimed(ctx context.Context, key string, item map[string]*dynamodb.AttributeValue, cond string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (bool, map[string]*dynamodb.AttributeValue, error) {
+	names, values = usedAttributes(cond, names, values)
+	tx := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					Item:                                item,
+					ConditionExpression:                 aws.String(cond),
+					ExpressionAttributeNames:            names,
+					ExpressionAttributeValues:           values,
+					ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+					TableName:                           aws.String(l.state.tableName),
+				},
+			},
+			l.claimRelease(key),
+		},
+	}
+	_, err := l.state.db.TransactWriteItemsWithContext(ctx, tx, l.requestOptions(ctx)...)
+	if err != nil {
+		if codes, ok := cancellationCodes(err); ok {
+			if codes[0] == "ConditionalCheckFailed" {
+				// Held, maybe by this node
+				return false, cancelledItem(err, 0), nil
+			}
+			if len(codes) > 1 && codes[1] == "ConditionalCheckFailed" {
+				return false, nil, errClaimed
+			}
+		}
+		return false, nil, err
+	}
+	return true, nil, nil
+}
+
+// claimRelease is a transaction item removing key's claim. It fails if another node holds an
+// unexpired claim on key.
+func (l *Locker) claimRelease(key string) *dynamodb.TransactWriteItem {
+	claimKey := map[string]*dynamodb.AttributeValue{}
+	claimKey[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(claimKeyPrefix + key)}
+	return &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			Key:                       claimKey,
+			ConditionExpression:       aws.String(l.claimCondition()),
+			ExpressionAttributeNames:  map[string]*string{"#key": aws.String(l.state.tableKey)},
+			ExpressionAttributeValues: l.claimValues(),
+			TableName:                 aws.String(l.state.tableName),
+		},
+	}
+}
+
+// claimCondition holds when a claim item is missing, expired or belongs to this node.
+func (l *Locker) claimCondition() string {
+	return fmt.Sprintf("attribute_not_exists(#key) OR nodeId = :nodeId OR :now > %s", claimExpColumn)
+}
+
+func (l *Locker) claimValues() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		":now":    {N: aws.String(strconv.FormatInt(millis(time.Now()), 10))},
+		":nodeId": {S: aws.String(l.state.nodeID)},
+	}
+}
+
+// anyOf joins conditions with OR.
+func anyOf(conds []string) string {
+	return "(" + strings.Join(conds, ") OR (") + ")"
+}
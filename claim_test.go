@@ -0,0 +1,235 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestClaim(t *testing.T) {
+	lk, ts := getTestLock(200, "{}")
+	defer ts.Close()
+
+	claimed, err := lk.Claim(context.Background(), "mylock", time.Now().Add(time.Minute))
+	if err != nil || !claimed {
+		t.Errorf("failed to claim - %v", err)
+	}
+}
+
+func TestClaimTaken(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+	defer ts.Close()
+
+	claimed, err := lk.Claim(context.Background(), "mylock", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Error("Expect no error when another node holds the claim")
+	}
+	if claimed {
+		t.Error("Should not have claimed")
+	}
+}
+
+// getClaimsTestLock returns a Locker with Claims enabled whose plain puts, i.e. renewals,
+// succeed if held and whose transactions are cancelled with the given reason codes, or succeed
+// if there are none. It also returns the operations called and the last PutItem request.
+func getClaimsTestLock(held bool, codes ...string) (*Locker, *httptest.Server, *[]string, *dynamodb.PutItemInput) {
+	var ops []string
+	put := &dynamodb.PutItemInput{}
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		ops = append(ops, op)
+		switch {
+		case op == "PutItem":
+			json.NewDecoder(r.Body).Decode(put)
+			if !held {
+				w.WriteHeader(400)
+				fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+				return
+			}
+			fmt.Fprintln(w, "{}")
+		case op == "TransactWriteItems" && len(codes) > 0:
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",`+
+				`"Message":"Transaction cancelled","CancellationReasons":[{"Code":"%s"}]}`, strings.Join(codes, `"},{"Code":"`))
+		default:
+			fmt.Fprintln(w, "{}")
+		}
+	})
+	lk.Claims = true
+	return lk, ts, &ops, put
+}
+
+func TestLockClaimsAcquire(t *testing.T) {
+	lk, ts, ops, _ := getClaimsTestLock(false)
+	defer ts.Close()
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	if strings.Join(*ops, ",") != "PutItem,TransactWriteItems" {
+		t.Errorf("Expected a renewal attempt and a single transaction got %v", *ops)
+	}
+}
+
+func TestLockClaimedByOther(t *testing.T) {
+	lk, ts, ops, _ := getClaimsTestLock(false, "None", "ConditionalCheckFailed")
+	defer ts.Close()
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil {
+		t.Error("Expect no error when another node holds the claim")
+	}
+	if locked {
+		t.Error("Should not have acquired a lock claimed by another node")
+	}
+	if strings.Join(*ops, ",") != "PutItem,TransactWriteItems" {
+		t.Errorf("Expected a renewal attempt and a single transaction got %v", *ops)
+	}
+}
+
+func TestLockClaimsRenew(t *testing.T) {
+	lk, ts, ops, put := getClaimsTestLock(true)
+	defer ts.Close()
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to renew - %v", err)
+	}
+	if strings.Join(*ops, ",") != "PutItem" {
+		t.Errorf("Expected renewal to be a single plain put got %v", *ops)
+	}
+	if cond := aws.StringValue(put.ConditionExpression); cond != "nodeId = :nodeId AND :now <= lease_expiration" {
+		t.Errorf("The plain put must only renew an unexpired lock, got %s", cond)
+	}
+	if len(put.ExpressionAttributeValues) != 2 || put.ExpressionAttributeValues[":nodeId"] == nil || put.ExpressionAttributeValues[":now"] == nil {
+		t.Errorf("Expected only the values the condition uses got %v", put.ExpressionAttributeValues)
+	}
+}
+
+func TestLockClaimsRenewCondition(t *testing.T) {
+	lk, ts, _, put := getClaimsTestLock(true)
+	defer ts.Close()
+	lk.Condition = &AttributeCondition{
+		Expression: "#p < :priority",
+		Names:      map[string]*string{"#p": aws.String("priority")},
+		Values:     map[string]*dynamodb.AttributeValue{":priority": {N: aws.String("5")}},
+	}
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to renew - %v", err)
+	}
+	if len(put.ExpressionAttributeNames) != 0 || len(put.ExpressionAttributeValues) != 2 {
+		t.Errorf("Expected the unused condition attributes to be dropped got %v %v",
+			put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+	}
+}
+
+func TestLockClaimsOwnExpired(t *testing.T) {
+	var ops []string
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		ops = append(ops, op)
+		w.WriteHeader(400)
+		switch len(ops) {
+		case 1:
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+		case 2:
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",`+
+				`"Message":"Transaction cancelled","CancellationReasons":[{"Code":"ConditionalCheckFailed",`+
+				`"Item":{"nodeId":{"S":"testNode12"},"lease_expiration":{"N":"1000"}}},{"Code":"None"}]}`)
+		default:
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",`+
+				`"Message":"Transaction cancelled","CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed"}]}`)
+		}
+	})
+	defer ts.Close()
+	lk.Claims = true
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || locked {
+		t.Errorf("Expected another node's claim to win over this node's expired lock got %v %v", locked, err)
+	}
+	if strings.Join(ops, ",") != "PutItem,TransactWriteItems,TransactWriteItems" {
+		t.Errorf("Expected the expired lock to be relocked through a transaction got %v", ops)
+	}
+}
+
+func TestLockClaimsQuota(t *testing.T) {
+	lk, ts, _, _ := getClaimsTestLock(false, "None", "None", "ConditionalCheckFailed")
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	locked, err := lk.Lock(context.Background(), "team:job1", time.Now().Add(10*time.Minute))
+	if err != nil || locked {
+		t.Errorf("Expected a counted acquisition to honor claims got %v %v", locked, err)
+	}
+}
+
+func TestDryRunClaimed(t *testing.T) {
+	claimExp := millis(time.Now().Add(time.Minute))
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), claimKeyPrefix) {
+			fmt.Fprintf(w, `{"Item":{"nodeId":{"S":"standby"},"claim_expiration":{"N":"%d"}}}`, claimExp)
+			return
+		}
+		fmt.Fprintln(w, `{}`)
+	})
+	defer ts.Close()
+	lk.Claims = true
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun())
+	if err != nil || locked {
+		t.Errorf("Expected a dry run to honor claims got %v %v", locked, err)
+	}
+}
+
+func TestDryRunClaimWithoutExpiration(t *testing.T) {
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), claimKeyPrefix) {
+			fmt.Fprintln(w, `{"Item":{"nodeId":{"S":"standby"}}}`)
+			return
+		}
+		fmt.Fprintln(w, `{}`)
+	})
+	defer ts.Close()
+	lk.Claims = true
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun())
+	if err != nil || locked {
+		t.Errorf("Expected a claim without expiration to be honored got %v %v", locked, err)
+	}
+}
+
+func TestDryRunClaimedOwnExpired(t *testing.T) {
+	past := millis(time.Now().Add(-time.Minute))
+	claimExp := millis(time.Now().Add(time.Minute))
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), claimKeyPrefix) {
+			fmt.Fprintf(w, `{"Item":{"nodeId":{"S":"standby"},"claim_expiration":{"N":"%d"}}}`, claimExp)
+			return
+		}
+		fmt.Fprintf(w, `{"Item":{"nodeId":{"S":"testNode12"},"lease_expiration":{"N":"%d"}}}`, past)
+	})
+	defer ts.Close()
+	lk.Claims = true
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun())
+	if err != nil || locked {
+		t.Errorf("Expected a dry run to honor claims against this node's expired lock got %v %v", locked, err)
+	}
+}
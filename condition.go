@@ -0,0 +1,79 @@
+package lock
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// AttributeCondition is an extra predicate a node must satisfy to take over a lock that
+// another node let expire. It enables domain specific handoff policies, e.g. only taking
+// over from nodes with a lower priority:
+//
+//	locker.Condition = &lock.AttributeCondition{
+//		Expression: "attribute_not_exists(priority) OR priority < :priority",
+//		Values:     map[string]*dynamodb.AttributeValue{":priority": {N: aws.String("5")}},
+//		Attributes: map[string]*dynamodb.AttributeValue{"priority": {N: aws.String("5")}},
+//	}
+//
+// The expression is ANDed with the expiration check only. Locking a key that is not
+// locked, or re-locking a key this node already holds, does not evaluate it.
+type AttributeCondition struct {
+	Expression string                              // DynamoDB condition expression
+	Names      map[string]*string                  // Expression attribute names used by Expression
+	Values     map[string]*dynamodb.AttributeValue // Expression attribute values used by Expression
+	Attributes map[string]*dynamodb.AttributeValue // Extra attributes written to the lock item on acquisition
+}
+
+// reservedValues are the expression attribute values used by the lock's own conditions.
+var reservedValues = []string{":now", ":nodeId", ":prevNodeId", ":prevExpiration"}
+
+// apply adds the condition to req and returns the takeover expression ANDed with it.
+func (c *AttributeCondition) apply(req *dynamodb.PutItemInput, takeover string) (string, error) {
+	for _, r := range reservedValues {
+		if _, ok := c.Values[r]; ok {
+			return "", fmt.Errorf("AttributeCondition value '%s' is reserved", r)
+		}
+	}
+	for k, v := range c.Values {
+		req.ExpressionAttributeValues[k] = v
+	}
+	if len(c.Names) > 0 && req.ExpressionAttributeNames == nil {
+		req.ExpressionAttributeNames = map[string]*string{}
+	}
+	for k, v := range c.Names {
+		req.ExpressionAttributeNames[k] = v
+	}
+	for k, v := range c.Attributes {
+		if _, ok := req.Item[k]; !ok {
+			req.Item[k] = v
+		}
+	}
+	return fmt.Sprintf("%s AND (%s)", takeover, c.Expression), nil
+}
+
+// placeholder matches expression attribute names and values.
+var placeholder = regexp.MustCompile(`[#:][A-Za-z0-9_]+`)
+
+// usedAttributes returns the expression attribute names and values expr refers to, nil if none.
+// DynamoDB rejects requests with names or values their expressions don't use.
+func usedAttributes(expr string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (map[string]*string, map[string]*dynamodb.AttributeValue) {
+	var usedNames map[string]*string
+	var usedValues map[string]*dynamodb.AttributeValue
+	for _, p := range placeholder.FindAllString(expr, -1) {
+		if v, ok := names[p]; ok {
+			if usedNames == nil {
+				usedNames = map[string]*string{}
+			}
+			usedNames[p] = v
+		}
+		if v, ok := values[p]; ok {
+			if usedValues == nil {
+				usedValues = map[string]*dynamodb.AttributeValue{}
+			}
+			usedValues[p] = v
+		}
+	}
+	return usedNames, usedValues
+}
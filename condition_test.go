@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestLockCondition(t *testing.T) {
+	var req dynamodb.PutItemInput
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	lk.Condition = &AttributeCondition{
+		Expression: "attribute_not_exists(priority) OR priority < :priority",
+		Values:     map[string]*dynamodb.AttributeValue{":priority": {N: aws.String("5")}},
+		Attributes: map[string]*dynamodb.AttributeValue{"priority": {N: aws.String("5")}},
+	}
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	cond := aws.StringValue(req.ConditionExpression)
+	if !strings.Contains(cond, ":now > lease_expiration AND (attribute_not_exists(priority) OR priority < :priority)") {
+		t.Errorf("Condition not ANDed with the takeover check: %s", cond)
+	}
+	if aws.StringValue(req.ExpressionAttributeValues[":priority"].N) != "5" {
+		t.Error("Condition values not sent")
+	}
+	if aws.StringValue(req.Item["priority"].N) != "5" {
+		t.Error("Condition attributes not written")
+	}
+}
+
+func TestLockConditionReserved(t *testing.T) {
+	lk, ts := getTestLock(200, "{}")
+	defer ts.Close()
+	lk.Condition = &AttributeCondition{
+		Expression: "priority < :now",
+		Values:     map[string]*dynamodb.AttributeValue{":now": {N: aws.String("5")}},
+	}
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if err == nil || locked {
+		t.Error("Expected an error when the condition uses a reserved value")
+	}
+}
+
+func TestLockConditionNames(t *testing.T) {
+	lk, ts := getTestLock(200, "{}")
+	defer ts.Close()
+	names := map[string]*string{"#p": aws.String("priority")}
+	lk.Condition = &AttributeCondition{
+		Expression: "#p < :priority",
+		Names:      names,
+		Values:     map[string]*dynamodb.AttributeValue{":priority": {N: aws.String("5")}},
+	}
+	lk.init.Do(lk.getState)
+
+	req := &dynamodb.PutItemInput{
+		Item:                      map[string]*dynamodb.AttributeValue{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+	}
+	if _, err := lk.state.condition.apply(req, ":now > lease_expiration"); err != nil {
+		t.Fatal(err)
+	}
+	req.ExpressionAttributeNames["#key"] = aws.String(DefaultTableKey)
+	if len(names) != 1 {
+		t.Errorf("The condition's names must not be shared between requests, got %v", names)
+	}
+}
+
+func TestUsedAttributes(t *testing.T) {
+	names := map[string]*string{"#p": aws.String("priority"), "#unused": aws.String("x")}
+	values := map[string]*dynamodb.AttributeValue{
+		":now":    {N: aws.String("1")},
+		":nodeId": {S: aws.String("node")},
+		":nowish": {N: aws.String("2")},
+	}
+	usedNames, usedValues := usedAttributes("nodeId = :nodeId OR (#p < :nowish)", names, values)
+	if len(usedNames) != 1 || usedNames["#p"] == nil {
+		t.Errorf("Unexpected names %v", usedNames)
+	}
+	if len(usedValues) != 2 || usedValues[":nodeId"] == nil || usedValues[":nowish"] == nil {
+		t.Errorf("Unexpected values %v", usedValues)
+	}
+	if n, v := usedAttributes("attribute_not_exists(lock_key)", names, values); n != nil || v != nil {
+		t.Errorf("Expected no attributes got %v %v", n, v)
+	}
+}
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Config is the part of a Locker's effective configuration, after defaults have been applied,
+// that determines how lock items are written and interpreted. It is JSON serializable so nodes
+// can publish it and compare it with their peers.
+type Config struct {
+	TableName string       `json:"table_name"`
+	TableKey  string       `json:"table_key"`
+	NodeID    string       `json:"node_id"`
+	Condition string       `json:"condition,omitempty"` // AttributeCondition expression, if any
+	Quota     *QuotaConfig `json:"quota,omitempty"`
+	Claims    bool         `json:"claims"`
+}
+
+// QuotaConfig describes a Locker's Quota.
+type QuotaConfig struct {
+	Limits          map[string]int64 `json:"limits,omitempty"`
+	Default         int64            `json:"default"`
+	CustomNamespace bool             `json:"custom_namespace"` // Quota.Namespace is set. Functions can't be compared
+}
+
+func (q *QuotaConfig) String() string {
+	if q == nil {
+		return "none"
+	}
+	limits := make([]string, 0, len(q.Limits))
+	for ns, limit := range q.Limits {
+		limits = append(limits, fmt.Sprintf("%s=%d", ns, limit))
+	}
+	sort.Strings(limits)
+	return fmt.Sprintf("{limits:[%s] default:%d custom_namespace:%v}", strings.Join(limits, " "), q.Default, q.CustomNamespace)
+}
+
+// Config returns the Locker's effective configuration.
+func (l *Locker) Config() Config {
+	l.init.Do(l.getState)
+	c := Config{
+		TableName: l.state.tableName,
+		TableKey:  l.state.tableKey,
+		NodeID:    l.state.nodeID,
+		Claims:    l.state.claims,
+	}
+	if l.state.condition != nil {
+		c.Condition = l.state.condition.Expression
+	}
+	if q := l.state.quota; q != nil {
+		c.Quota = &QuotaConfig{Default: q.Default, CustomNamespace: q.Namespace != nil}
+		if len(q.Limits) > 0 {
+			c.Quota.Limits = map[string]int64{}
+			for ns, limit := range q.Limits {
+				c.Quota.Limits[ns] = limit
+			}
+		}
+	}
+	return c
+}
+
+// Diff describes every setting that differs between c and other as "Field: c's value != other's value".
+// NodeID is ignored since it is expected to differ between nodes. An empty result means both
+// Lockers write and interpret lock items the same way, unless they use different custom quota
+// namespaces, which can't be compared.
+func (c Config) Diff(other Config) []string {
+	return diffFields(c, other, "NodeID")
+}
+
+// diffFields describes the fields of the structs a and b that differ, except ignored.
+func diffFields(a, b interface{}, ignored string) []string {
+	var diffs []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		if name == ignored {
+			continue
+		}
+		if fa, fb := va.Field(i).Interface(), vb.Field(i).Interface(); !reflect.DeepEqual(fa, fb) {
+			diffs = append(diffs, fmt.Sprintf("%s: %v != %v", name, fa, fb))
+		}
+	}
+	return diffs
+}
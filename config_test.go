@@ -0,0 +1,47 @@
+package lock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	lk := &Locker{NodeID: "testNode12"}
+	c := lk.Config()
+	expected := Config{TableName: DefaultTableName, TableKey: DefaultTableKey, NodeID: "testNode12"}
+	if c != expected {
+		t.Errorf("Expected %+v got %+v", expected, c)
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	a := (&Locker{NodeID: "node1"}).Config()
+	b := (&Locker{
+		NodeID:    "node2",
+		TableKey:  "id",
+		Condition: &AttributeCondition{Expression: "priority < :p"},
+		Audit:     &testPublisher{},
+	}).Config()
+
+	if diffs := a.Diff(a); len(diffs) != 0 {
+		t.Errorf("Expected no differences got %v", diffs)
+	}
+	expected := []string{"TableKey: lock_key != id", "Condition:  != priority < :p"}
+	if diffs := a.Diff(b); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Expected %v got %v", expected, diffs)
+	}
+}
+
+func TestConfigDiffQuota(t *testing.T) {
+	a := (&Locker{NodeID: "node1", Quota: &Quota{Limits: map[string]int64{"team": 5}}}).Config()
+	b := (&Locker{NodeID: "node2", Quota: &Quota{Limits: map[string]int64{"team": 5}}}).Config()
+	c := (&Locker{NodeID: "node3", Quota: &Quota{Limits: map[string]int64{"team": 10}}}).Config()
+
+	if diffs := a.Diff(b); len(diffs) != 0 {
+		t.Errorf("Expected equal quotas to match got %v", diffs)
+	}
+	expected := []string{"Quota: {limits:[team=5] default:0 custom_namespace:false} != {limits:[team=10] default:0 custom_namespace:false}"}
+	if diffs := a.Diff(c); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Expected %v got %v", expected, diffs)
+	}
+}
@@ -0,0 +1,126 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults for unset ContentionPolicy fields.
+const (
+	DefaultContentionWindow    = time.Minute
+	DefaultContentionThreshold = 5
+	DefaultContentionCoolDown  = 30 * time.Second
+)
+
+// ContentionPolicy configures how a Locker tracks keys it repeatedly fails to lock.
+// When a key's Lock calls fail Threshold times within Window, because the key is held by
+// another node or because of an error, the key is cooling down for CoolDown. Cooling down
+// is advisory: Lock still attempts the key, but schedulers can check ShouldSkip or
+// RetryAfter and spend their attempts on other keys. A successful Lock resets the key.
+type ContentionPolicy struct {
+	Window    time.Duration // Defaults to DefaultContentionWindow
+	Threshold int           // Defaults to DefaultContentionThreshold
+	CoolDown  time.Duration // Defaults to DefaultContentionCoolDown
+}
+
+// ShouldSkip reports whether key is cooling down after persistent contention.
+// It always returns false if the Locker has no ContentionPolicy.
+func (l *Locker) ShouldSkip(key string) bool {
+	return l.RetryAfter(key) > 0
+}
+
+// RetryAfter returns how long key remains cooling down after persistent contention,
+// or zero if it is not. It always returns zero if the Locker has no ContentionPolicy.
+func (l *Locker) RetryAfter(key string) time.Duration {
+	l.init.Do(l.getState)
+	if l.state.contention == nil {
+		return 0
+	}
+	return l.state.contention.retryAfter(key, time.Now())
+}
+
+type contention struct {
+	policy  ContentionPolicy
+	mu      sync.Mutex
+	keys    map[string]*keyContention
+	sweepAt int // Size of keys at which stale entries are swept
+}
+
+type keyContention struct {
+	failures  []time.Time // Failures within the window, oldest first
+	skipUntil time.Time
+}
+
+func newContention(p ContentionPolicy) *contention {
+	if p.Window <= 0 {
+		p.Window = DefaultContentionWindow
+	}
+	if p.Threshold <= 0 {
+		p.Threshold = DefaultContentionThreshold
+	}
+	if p.CoolDown <= 0 {
+		p.CoolDown = DefaultContentionCoolDown
+	}
+	return &contention{policy: p, keys: map[string]*keyContention{}, sweepAt: 1024}
+}
+
+func (c *contention) record(key string, locked bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if locked {
+		delete(c.keys, key)
+		return
+	}
+	k, ok := c.keys[key]
+	if !ok {
+		if len(c.keys) >= c.sweepAt {
+			c.sweep(now)
+		}
+		k = &keyContention{}
+		c.keys[key] = k
+	}
+	k.failures = append(c.recent(k.failures, now), now)
+	if len(k.failures) >= c.policy.Threshold {
+		k.failures = nil
+		k.skipUntil = now.Add(c.policy.CoolDown)
+	}
+}
+
+func (c *contention) retryAfter(key string, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, ok := c.keys[key]
+	if !ok {
+		return 0
+	}
+	if wait := k.skipUntil.Sub(now); wait > 0 {
+		return wait
+	}
+	// Forget keys that are neither cooling down nor failing recently
+	if k.failures = c.recent(k.failures, now); len(k.failures) == 0 {
+		delete(c.keys, key)
+	}
+	return 0
+}
+
+// sweep forgets keys that are neither cooling down nor failing recently.
+func (c *contention) sweep(now time.Time) {
+	for key, k := range c.keys {
+		if k.failures = c.recent(k.failures, now); len(k.failures) == 0 && !now.Before(k.skipUntil) {
+			delete(c.keys, key)
+		}
+	}
+	c.sweepAt = 2 * len(c.keys)
+	if c.sweepAt < 1024 {
+		c.sweepAt = 1024
+	}
+}
+
+// recent drops the failures that are outside the window.
+func (c *contention) recent(failures []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-c.policy.Window)
+	for len(failures) > 0 && failures[0].Before(cutoff) {
+		failures = failures[1:]
+	}
+	return failures
+}
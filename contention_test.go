@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestContentionCoolDown(t *testing.T) {
+	c := newContention(ContentionPolicy{Window: time.Minute, Threshold: 3, CoolDown: 30 * time.Second})
+	now := time.Now()
+
+	c.record("mylock", false, now)
+	c.record("mylock", false, now.Add(10*time.Second))
+	if wait := c.retryAfter("mylock", now.Add(10*time.Second)); wait != 0 {
+		t.Errorf("Expected no cool down below the threshold got %s", wait)
+	}
+	c.record("mylock", false, now.Add(20*time.Second))
+	if wait := c.retryAfter("mylock", now.Add(30*time.Second)); wait != 20*time.Second {
+		t.Errorf("Expected 20s of cool down left got %s", wait)
+	}
+	if wait := c.retryAfter("mylock", now.Add(time.Minute)); wait != 0 {
+		t.Errorf("Expected the cool down to end got %s", wait)
+	}
+}
+
+func TestContentionWindow(t *testing.T) {
+	c := newContention(ContentionPolicy{Window: time.Minute, Threshold: 3})
+	now := time.Now()
+
+	c.record("mylock", false, now)
+	c.record("mylock", false, now.Add(50*time.Second))
+	c.record("mylock", false, now.Add(70*time.Second))
+	if wait := c.retryAfter("mylock", now.Add(70*time.Second)); wait != 0 {
+		t.Errorf("Expected failures outside the window to be forgotten got %s", wait)
+	}
+}
+
+func TestContentionReset(t *testing.T) {
+	c := newContention(ContentionPolicy{Threshold: 2})
+	now := time.Now()
+
+	c.record("mylock", false, now)
+	c.record("mylock", true, now)
+	c.record("mylock", false, now)
+	if wait := c.retryAfter("mylock", now); wait != 0 {
+		t.Errorf("Expected a successful lock to reset the key got %s", wait)
+	}
+}
+
+func TestShouldSkip(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+	defer ts.Close()
+	lk.Contention = &ContentionPolicy{Threshold: 2}
+
+	for i := 0; i < 2; i++ {
+		if lk.ShouldSkip("mylock") {
+			t.Fatalf("Should not skip after %d failures", i)
+		}
+		lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	}
+	if !lk.ShouldSkip("mylock") {
+		t.Error("Expected a persistently contended key to be skipped")
+	}
+	if lk.ShouldSkip("otherlock") {
+		t.Error("Contention on one key should not affect another")
+	}
+}
+
+func TestContentionSweep(t *testing.T) {
+	c := newContention(ContentionPolicy{Window: time.Minute})
+	now := time.Now()
+	for i := 0; i < 1024; i++ {
+		c.record(fmt.Sprintf("key%d", i), false, now)
+	}
+	c.record("mylock", false, now.Add(2*time.Minute))
+	if len(c.keys) != 1 || c.keys["mylock"] == nil {
+		t.Errorf("Expected stale keys to be swept, %d keys left", len(c.keys))
+	}
+}
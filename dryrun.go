@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// dryLock reports whether Lock would currently grant key to this node.
+func (l *Locker) dryLock(ctx context.Context, key string) (bool, error) {
+	item, err := l.getItem(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	now := millis(time.Now())
+	if item != nil {
+		// With claims this node's expired lock is acquired like any other
+		own := stringAttr(item, "nodeId") == l.state.nodeID
+		if own && !l.state.claims {
+			return true, nil
+		}
+		if _, ok := item[expColumnName]; !ok {
+			// Lock's condition can't compare against a missing expiration
+			return false, nil
+		}
+		exp, err := numberAttr(item, expColumnName)
+		if err != nil {
+			return false, err
+		}
+		if now <= exp {
+			return own, nil
+		}
+		if l.state.condition != nil && !own {
+			return false, ErrConditionNotEvaluated
+		}
+	}
+	if l.state.claims {
+		claim, err := l.getItem(ctx, claimKeyPrefix+key)
+		if err != nil {
+			return false, err
+		}
+		if claim != nil && stringAttr(claim, "nodeId") != l.state.nodeID {
+			if _, ok := claim[claimExpColumn]; !ok {
+				// The claim condition can't compare against a missing expiration
+				return false, nil
+			}
+			exp, err := numberAttr(claim, claimExpColumn)
+			if err != nil {
+				return false, err
+			}
+			if now <= exp {
+				return false, nil
+			}
+		}
+	}
+	if ns, limit := l.state.quota.limit(key); item == nil && limit > 0 {
+		counter, err := l.getItem(ctx, quotaKeyPrefix+ns)
+		if err != nil {
+			return false, err
+		}
+		count, err := numberAttr(counter, quotaCountColumn)
+		if err != nil {
+			return false, err
+		}
+		if count >= limit {
+			return false, fmt.Errorf("namespace '%s': %w", ns, ErrQuotaExceeded)
+		}
+	}
+	return true, nil
+}
+
+// dryUnlock returns the error Unlock would currently return for key.
+func (l *Locker) dryUnlock(ctx context.Context, key string) error {
+	item, err := l.getItem(ctx, key)
+	if err != nil {
+		return err
+	}
+	if item != nil && stringAttr(item, "nodeId") != l.state.nodeID {
+		return errNotOwned(key)
+	}
+	return nil
+}
+
+// getItem does a consistent read of key's lock table entry. A missing entry is returned as nil.
+func (l *Locker) getItem(ctx context.Context, key string) (map[string]*dynamodb.AttributeValue, error) {
+	dynamoKey := map[string]*dynamodb.AttributeValue{}
+	dynamoKey[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(key)}
+	resp, err := l.state.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:            dynamoKey,
+		ConsistentRead: aws.Bool(true),
+		TableName:      aws.String(l.state.tableName),
+	}, l.requestOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Item) == 0 {
+		return nil, nil
+	}
+	return resp.Item, nil
+}
+
+// numberAttr returns the number attribute name from item or 0 if it is not set.
+func numberAttr(item map[string]*dynamodb.AttributeValue, name string) (int64, error) {
+	v, ok := item[name]
+	if !ok || v.N == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(*v.N, 10, 64)
+}
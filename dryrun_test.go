@@ -0,0 +1,106 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getDryRunTestLock(t *testing.T, item string) (*Locker, func()) {
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		if op := r.Header.Get("X-Amz-Target"); !strings.HasSuffix(op, ".GetItem") {
+			t.Errorf("Dry runs must only read, got %s", op)
+		}
+		fmt.Fprintln(w, item)
+	})
+	return lk, ts.Close
+}
+
+func TestDryRunLock(t *testing.T) {
+	future := millis(time.Now().Add(time.Hour))
+	past := millis(time.Now().Add(-time.Hour))
+	cases := []struct {
+		name   string
+		item   string
+		locked bool
+	}{
+		{"unlocked", `{}`, true},
+		{"owned", fmt.Sprintf(`{"Item":{"nodeId":{"S":"testNode12"},"lease_expiration":{"N":"%d"}}}`, future), true},
+		{"expired", fmt.Sprintf(`{"Item":{"nodeId":{"S":"otherNode"},"lease_expiration":{"N":"%d"}}}`, past), true},
+		{"held", fmt.Sprintf(`{"Item":{"nodeId":{"S":"otherNode"},"lease_expiration":{"N":"%d"}}}`, future), false},
+	}
+	for _, c := range cases {
+		lk, done := getDryRunTestLock(t, c.item)
+		locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun())
+		done()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if locked != c.locked {
+			t.Errorf("%s: expected %v got %v", c.name, c.locked, locked)
+		}
+	}
+}
+
+func TestDryRunLockCondition(t *testing.T) {
+	past := millis(time.Now().Add(-time.Hour))
+	cases := []struct {
+		name   string
+		item   string
+		locked bool
+		err    error
+	}{
+		{"unlocked", `{}`, true, nil},
+		{"owned", fmt.Sprintf(`{"Item":{"nodeId":{"S":"testNode12"},"lease_expiration":{"N":"%d"}}}`, past), true, nil},
+		{"expired", fmt.Sprintf(`{"Item":{"nodeId":{"S":"otherNode"},"lease_expiration":{"N":"%d"}}}`, past), false, ErrConditionNotEvaluated},
+	}
+	for _, c := range cases {
+		lk, done := getDryRunTestLock(t, c.item)
+		lk.Condition = &AttributeCondition{Expression: "attribute_not_exists(priority)"}
+		locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun())
+		done()
+		if err != c.err {
+			t.Errorf("%s: expected error %v got %v", c.name, c.err, err)
+		}
+		if locked != c.locked {
+			t.Errorf("%s: expected %v got %v", c.name, c.locked, locked)
+		}
+	}
+}
+
+func TestDryRunLockQuotaExceeded(t *testing.T) {
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), quotaKeyPrefix) {
+			fmt.Fprintln(w, `{"Item":{"lock_count":{"N":"2"}}}`)
+			return
+		}
+		fmt.Fprintln(w, `{}`)
+	})
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	locked, err := lk.Lock(context.Background(), "team:job1", time.Now().Add(10*time.Minute), WithDryRun())
+	if locked || !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded got %v %v", locked, err)
+	}
+}
+
+func TestDryRunUnlock(t *testing.T) {
+	lk, done := getDryRunTestLock(t, `{"Item":{"nodeId":{"S":"otherNode"}}}`)
+	defer done()
+	if err := lk.Unlock(context.Background(), "mylock", WithDryRun()); err == nil {
+		t.Error("Expected an error when unlocking a lock we don't own.")
+	}
+
+	lk, done = getDryRunTestLock(t, `{}`)
+	defer done()
+	if err := lk.Unlock(context.Background(), "mylock", WithDryRun()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// LockInfo describes a lock entry in the lock table.
+type LockInfo struct {
+	Key        string
+	NodeID     string
+	Expiration time.Time
+}
+
+// Expiring returns the locks in the table that are currently held and will expire within the
+// given duration, ordered by expiration. It scans the whole table so it is intended for
+// operators preparing a failover rather than for use on a hot path.
+func (l *Locker) Expiring(ctx context.Context, within time.Duration) ([]LockInfo, error) {
+	l.init.Do(l.getState)
+	now := time.Now()
+	req := &dynamodb.ScanInput{
+		ConsistentRead:       aws.Bool(true),
+		FilterExpression:     aws.String(fmt.Sprintf("%s BETWEEN :now AND :until", expColumnName)),
+		ProjectionExpression: aws.String(fmt.Sprintf("#key, nodeId, %s", expColumnName)),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(l.state.tableKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   {N: aws.String(strconv.FormatInt(millis(now), 10))},
+			":until": {N: aws.String(strconv.FormatInt(millis(now.Add(within)), 10))},
+		},
+		TableName: aws.String(l.state.tableName),
+	}
+	var locks []LockInfo
+	var parseErr error
+	err := l.state.db.ScanPagesWithContext(ctx, req, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			info, err := l.lockInfo(item)
+			if err != nil {
+				parseErr = err
+				return false
+			}
+			locks = append(locks, info)
+		}
+		return true
+	}, l.requestOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Expiration.Before(locks[j].Expiration)
+	})
+	return locks, nil
+}
+
+// lockInfo decodes a lock table item.
+func (l *Locker) lockInfo(item map[string]*dynamodb.AttributeValue) (LockInfo, error) {
+	info := LockInfo{
+		Key:    stringAttr(item, l.state.tableKey),
+		NodeID: stringAttr(item, "nodeId"),
+	}
+	if exp, ok := item[expColumnName]; ok {
+		ms, err := strconv.ParseInt(aws.StringValue(exp.N), 10, 64)
+		if err != nil {
+			return info, fmt.Errorf("Key '%s' has an invalid %s: %v", info.Key, expColumnName, err)
+		}
+		info.Expiration = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return info, nil
+}
+
+// stringAttr returns the string attribute name from item or "" if it is not set.
+func stringAttr(item map[string]*dynamodb.AttributeValue, name string) string {
+	if v, ok := item[name]; ok {
+		return aws.StringValue(v.S)
+	}
+	return ""
+}
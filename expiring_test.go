@@ -0,0 +1,37 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpiring(t *testing.T) {
+	lk, ts := getTestLock(200, `{"Count":2,"Items":[`+
+		`{"lock_key":{"S":"b"},"nodeId":{"S":"node2"},"lease_expiration":{"N":"2000"}},`+
+		`{"lock_key":{"S":"a"},"nodeId":{"S":"node1"},"lease_expiration":{"N":"1000"}}]}`)
+	defer ts.Close()
+
+	locks, err := lk.Expiring(context.Background(), 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 {
+		t.Fatalf("Expected 2 locks got %d", len(locks))
+	}
+	if locks[0].Key != "a" || locks[0].NodeID != "node1" || !locks[0].Expiration.Equal(time.Unix(1, 0)) {
+		t.Errorf("Unexpected first lock %+v", locks[0])
+	}
+	if locks[1].Key != "b" {
+		t.Errorf("Expected locks ordered by expiration got %+v", locks)
+	}
+}
+
+func TestExpiringError(t *testing.T) {
+	lk, ts := getTestLock(500, "{}")
+	defer ts.Close()
+
+	if _, err := lk.Expiring(context.Background(), 10*time.Minute); err == nil {
+		t.Error("Expiring should return an error when the scan fails")
+	}
+}
@@ -0,0 +1,59 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrNotHeld is returned when an operation requires the lock to be held by this node
+// and it is not, either because another node owns it or because it has expired.
+var ErrNotHeld = errors.New("lock is not held by this node")
+
+// GuardedUpdate applies update to an item in table only if this node currently holds an
+// unexpired lock on key. The ownership check and the update are executed in a single
+// DynamoDB transaction so the update can never land after the lock has been lost.
+//
+// update is used as-is except for its TableName which is set to table. If the lock is not
+// held the returned error wraps ErrNotHeld. If the update's own ConditionExpression fails
+// the *dynamodb.TransactionCanceledException is returned unchanged.
+func (l *Locker) GuardedUpdate(ctx context.Context, key, table string, update *dynamodb.Update) error {
+	l.init.Do(l.getState)
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	u := *update
+	u.TableName = aws.String(table)
+
+	dynamoKey := map[string]*dynamodb.AttributeValue{}
+	dynamoKey[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(key)}
+	req := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					Key:                 dynamoKey,
+					ConditionExpression: aws.String(fmt.Sprintf("nodeId = :nodeId AND :now <= %s", expColumnName)),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":now":    {N: aws.String(strconv.FormatInt(millis(time.Now()), 10))},
+						":nodeId": {S: aws.String(l.state.nodeID)},
+					},
+					TableName: aws.String(l.state.tableName),
+				},
+			},
+			{Update: &u},
+		},
+	}
+	_, err := l.state.db.TransactWriteItemsWithContext(ctx, req, l.requestOptions(ctx)...)
+	if err != nil {
+		if codes, ok := cancellationCodes(err); ok && codes[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("key '%s': %w", key, ErrNotHeld)
+		}
+		return err
+	}
+	return nil
+}
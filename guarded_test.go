@@ -0,0 +1,56 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func testUpdate() *dynamodb.Update {
+	return &dynamodb.Update{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String("account1")},
+		},
+		UpdateExpression: aws.String("SET balance = :b"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":b": {N: aws.String("10")},
+		},
+	}
+}
+
+func TestGuardedUpdateSuccess(t *testing.T) {
+	lk, ts := getTestLock(200, "{}")
+	defer ts.Close()
+
+	err := lk.GuardedUpdate(context.Background(), "mylock", "accounts", testUpdate())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGuardedUpdateNotHeld(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","Message":"Transaction cancelled",`+
+			`"CancellationReasons":[{"Code":"ConditionalCheckFailed"},{"Code":"None"}]}`)
+	defer ts.Close()
+
+	err := lk.GuardedUpdate(context.Background(), "mylock", "accounts", testUpdate())
+	if !errors.Is(err, ErrNotHeld) {
+		t.Errorf("Expected ErrNotHeld got %v", err)
+	}
+}
+
+func TestGuardedUpdateConditionFailed(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","Message":"Transaction cancelled",`+
+			`"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed"}]}`)
+	defer ts.Close()
+
+	err := lk.GuardedUpdate(context.Background(), "mylock", "accounts", testUpdate())
+	if err == nil || errors.Is(err, ErrNotHeld) {
+		t.Errorf("Expected the update's condition failure got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// RequestHook is called with the caller's context for every DynamoDB request a Locker makes,
+// before the request is signed and sent. It can attach values from ctx, such as a tenant or
+// request ID, so server side access logs and cost allocation can attribute lock traffic:
+//
+//	locker.RequestHook = func(ctx context.Context, r *request.Request) {
+//		if id, ok := ctx.Value(requestIDKey).(string); ok {
+//			r.HTTPRequest.Header.Set("X-Request-Id", id)
+//			request.AddToUserAgent(r, "req/"+id)
+//		}
+//	}
+type RequestHook func(ctx context.Context, r *request.Request)
+
+// requestOptions returns the SDK request options that apply the Locker's RequestHook.
+func (l *Locker) requestOptions(ctx context.Context) []request.Option {
+	if l.state.hook == nil {
+		return nil
+	}
+	return []request.Option{func(r *request.Request) {
+		l.state.hook(ctx, r)
+	}}
+}
+
+// AdmissionHook is called before every Lock attempt with the key, the Locker's NodeID and the
+// requested lock duration. A non-nil error rejects the attempt and is returned from Lock as is.
+// It lets platform teams enforce naming conventions, TTL limits and allow-lists in one place:
+//
+//	locker.Admit = func(key, nodeID string, ttl time.Duration) error {
+//		if ttl > time.Hour {
+//			return fmt.Errorf("lock '%s' requested for %s, the limit is 1h", key, ttl)
+//		}
+//		return nil
+//	}
+type AdmissionHook func(key, nodeID string, ttl time.Duration) error
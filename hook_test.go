@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+type tenantKey struct{}
+
+func TestRequestHook(t *testing.T) {
+	var headers []string
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("X-Tenant"))
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	lk.RequestHook = func(ctx context.Context, r *request.Request) {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			r.HTTPRequest.Header.Set("X-Tenant", tenant)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "team-a")
+	if _, err := lk.Lock(ctx, "mylock", time.Now().Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := lk.Unlock(ctx, "mylock"); err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 || headers[0] != "team-a" || headers[1] != "team-a" {
+		t.Errorf("Expected tenant header on every request got %v", headers)
+	}
+}
+
+func TestAdmissionHook(t *testing.T) {
+	requests := 0
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	rejected := errors.New("rejected")
+	var ttl time.Duration
+	lk.Admit = func(key, nodeID string, d time.Duration) error {
+		ttl = d
+		if !strings.HasPrefix(key, "team:") || nodeID != "testNode12" {
+			return rejected
+		}
+		return nil
+	}
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if locked || err != rejected {
+		t.Errorf("Expected the admission error got %v %v", locked, err)
+	}
+	if requests != 0 {
+		t.Error("Rejected attempts must not reach the table")
+	}
+	if ttl <= 9*time.Minute || ttl > 10*time.Minute {
+		t.Errorf("Unexpected ttl %s", ttl)
+	}
+
+	locked, err = lk.Lock(context.Background(), "team:mylock", time.Now().Add(10*time.Minute))
+	if !locked || err != nil {
+		t.Errorf("failed to lock - %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+// outcomes remembers Lock results by idempotency token.
+type outcomes struct {
+	mu      sync.Mutex
+	results map[outcomeKey]outcome
+}
+
+type outcomeKey struct {
+	token string
+	key   string
+}
+
+type outcome struct {
+	locked     bool
+	expiration time.Time
+}
+
+// get returns the outcome remembered for a call with the same token, key and expiration.
+func (o *outcomes) get(token, key string, expiration, now time.Time) (locked, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	res, ok := o.results[outcomeKey{token, key}]
+	// A different expiration is a new request: answering it from the earlier outcome would
+	// report a lease the table doesn't have
+	if !ok || !res.expiration.Equal(expiration) || now.After(res.expiration) {
+		return false, false
+	}
+	return res.locked, true
+}
+
+func (o *outcomes) put(token, key string, locked bool, expiration, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.results == nil {
+		o.results = map[outcomeKey]outcome{}
+	}
+	// Outcomes are only useful until the lock they describe expires.
+	for k, res := range o.results {
+		if now.After(res.expiration) {
+			delete(o.results, k)
+		}
+	}
+	o.results[outcomeKey{token, key}] = outcome{locked: locked, expiration: expiration}
+}
+
+// forget drops the outcomes for key under every token, e.g. once it is unlocked and a
+// remembered acquisition no longer holds.
+func (o *outcomes) forget(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for k := range o.results {
+		if k.key == key {
+			delete(o.results, k)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockIdempotencyToken(t *testing.T) {
+	requests := 0
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	ctx := context.Background()
+	exp := time.Now().Add(10 * time.Minute)
+
+	for i := 0; i < 3; i++ {
+		locked, err := lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+		if err != nil || !locked {
+			t.Fatalf("failed to lock - %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected retries with the same token to be served locally, got %d requests", requests)
+	}
+
+	lk.Lock(ctx, "otherlock", exp, WithIdempotencyToken("req-1"))
+	lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-2"))
+	if requests != 3 {
+		t.Errorf("Expected a new key or token to write to the table, got %d requests", requests)
+	}
+}
+
+func TestLockIdempotencyTokenExpired(t *testing.T) {
+	requests := 0
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	ctx := context.Background()
+	exp := time.Now().Add(-time.Second)
+
+	lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+	lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+	if requests != 2 {
+		t.Errorf("Expected outcomes past their expiration to be forgotten, got %d requests", requests)
+	}
+}
+
+func TestLockIdempotencyTokenExpiration(t *testing.T) {
+	requests := 0
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	ctx := context.Background()
+	exp := time.Now().Add(10 * time.Minute)
+
+	lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+	lk.Lock(ctx, "mylock", exp.Add(10*time.Minute), WithIdempotencyToken("req-1"))
+	if requests != 2 {
+		t.Errorf("Expected a later expiration to be written to the table, got %d requests", requests)
+	}
+}
+
+func TestLockIdempotencyTokenUnlock(t *testing.T) {
+	var ops []string
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		ops = append(ops, strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."))
+		if len(ops) == 3 {
+			// Another node locked the key in the meantime
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+			return
+		}
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	ctx := context.Background()
+	exp := time.Now().Add(10 * time.Minute)
+
+	if locked, err := lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1")); err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	if err := lk.Unlock(ctx, "mylock"); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Error("A retry after unlocking must not report the released lock as held")
+	}
+	if strings.Join(ops, ",") != "PutItem,DeleteItem,PutItem" {
+		t.Errorf("Expected the retry to write to the table got %v", ops)
+	}
+}
+
+func TestLockIdempotencyTokenError(t *testing.T) {
+	lk, ts := getTestLock(500, "{}")
+	defer ts.Close()
+	ctx := context.Background()
+	exp := time.Now().Add(10 * time.Minute)
+
+	lk.Lock(ctx, "mylock", exp, WithIdempotencyToken("req-1"))
+	if _, ok := lk.state.outcomes.get("req-1", "mylock", exp, time.Now()); ok {
+		t.Error("Failed lock attempts should not be remembered")
+	}
+}
@@ -30,15 +30,23 @@ To avoid split-brain issues:
  - only use this package on servers you control running NTP.
  - Don't rely on lock expirations granularity less than few a seconds.
  - Pad lock nnexpiration times.
+
+Reserved keys:
+
+Quotas, claims and schema validation store bookkeeping items in the lock table itself under
+keys starting with "_quota:", "_claim:" and the "_meta" key. Lock, Unlock, Claim and
+GuardedUpdate reject these keys with ErrReservedKey.
 */
 
 package lock
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -55,63 +63,223 @@ const (
 )
 
 type Locker struct {
-	TableName string // Dynamo table name. Defaults to "locks"
-	TableKey  string // Dynamo table primary key name. Defaults to "lock_key""
-	NodeID    string // Node ID to use. Defaults to host name
-	DB        *dynamodb.DynamoDB
-	init      sync.Once
-	state     *state
+	TableName      string // Dynamo table name. Defaults to "locks"
+	TableKey       string // Dynamo table primary key name. Defaults to "lock_key""
+	NodeID         string // Node ID to use. Defaults to host name
+	DB             *dynamodb.DynamoDB
+	Audit          Publisher           // Optional sink for lock lifecycle events
+	NodeMetadata   bool                // Record the node's ECS task, EC2 instance, pod and AZ on each lock
+	Condition      *AttributeCondition // Optional extra predicate for taking over expired locks
+	RequestHook    RequestHook         // Optional hook run on every DynamoDB request
+	Quota          *Quota              // Optional per namespace limits on concurrently held locks
+	Admit          AdmissionHook       // Optional policy check run before every lock attempt
+	Contention     *ContentionPolicy   // Optional tracking of persistently contended keys
+	Claims         bool                // Honor next owner claims made with Claim
+	ValidateSchema bool                // Check the table's "_meta" item matches these settings on first use. See WriteSchema
+	init           sync.Once
+	state          *state
 }
 
 type state struct {
-	tableName string
-	tableKey  string
-	nodeID    string
-	db        *dynamodb.DynamoDB
+	tableName      string
+	tableKey       string
+	nodeID         string
+	db             *dynamodb.DynamoDB
+	audit          Publisher
+	nodeMeta       *metadataCache
+	condition      *AttributeCondition
+	outcomes       outcomes
+	hook           RequestHook
+	quota          *Quota
+	admit          AdmissionHook
+	contention     *contention
+	claims         bool
+	validateSchema bool
+	schema         schemaCheck
 }
 
 // Lock attempts to grant exclusive access to the given key until the expiration.
 // Lock will return false if the lock is currently held by another node otherwise true.
 // A node can re-lock the same. A non-nil error means the lock was not granted.
-func (l *Locker) Lock(ctx context.Context, key string, expiration time.Time) (locked bool, e error) {
+func (l *Locker) Lock(ctx context.Context, key string, expiration time.Time, opts ...Option) (locked bool, e error) {
 	l.init.Do(l.getState)
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	if l.state.admit != nil {
+		if err := l.state.admit(key, l.state.nodeID, time.Until(expiration)); err != nil {
+			return false, err
+		}
+	}
+	o := getOptions(opts)
+	if err := l.validateSchema(ctx, !o.dryRun); err != nil {
+		return false, err
+	}
+	if o.dryRun {
+		return l.dryLock(ctx, key)
+	}
+	if o.token != "" {
+		if locked, ok := l.state.outcomes.get(o.token, key, expiration, time.Now()); ok {
+			return locked, nil
+		}
+	}
+	locked, err := l.lock(ctx, key, expiration)
+	if l.state.contention != nil {
+		l.state.contention.record(key, locked, time.Now())
+	}
+	if err != nil {
+		return false, err
+	}
+	if o.token != "" {
+		l.state.outcomes.put(o.token, key, locked, expiration, time.Now())
+	}
+	return locked, nil
+}
+
+func (l *Locker) lock(ctx context.Context, key string, expiration time.Time) (bool, error) {
 	// Conditional put on item not present
-	now := time.Now().UnixNano() / int64(time.Millisecond)
+	now := millis(time.Now())
 	nowString := strconv.FormatInt(now, 10)
-	expString := strconv.FormatInt(expiration.UnixNano()/int64(time.Millisecond), 10)
+	exp := millis(expiration)
+	expString := strconv.FormatInt(exp, 10)
 	entryNotExist := fmt.Sprintf("attribute_not_exists(%s)", l.state.tableKey)
 	owned := "nodeId = :nodeId"
 	alreadyExpired := fmt.Sprintf(":now > %s", expColumnName)
 
 	item := map[string]*dynamodb.AttributeValue{}
+	if l.state.nodeMeta != nil {
+		for k, v := range l.state.nodeMeta.get(ctx) {
+			item[k] = v
+		}
+	}
 	item[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(key)}
 	item["nodeId"] = &dynamodb.AttributeValue{S: aws.String(l.state.nodeID)}
 	item[expColumnName] = &dynamodb.AttributeValue{N: aws.String(expString)}
 	req := &dynamodb.PutItemInput{
-		Item:                item,
-		ConditionExpression: aws.String(fmt.Sprintf("(%s) OR (%s) OR (%s)", entryNotExist, owned, alreadyExpired)),
+		Item: item,
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":now":    &dynamodb.AttributeValue{N: aws.String(nowString)},
 			":nodeId": &dynamodb.AttributeValue{S: aws.String(l.state.nodeID)},
 		},
 		TableName: aws.String(l.state.tableName),
 	}
-	_, err := l.state.db.PutItemWithContext(ctx, req)
+	takeover := alreadyExpired
+	if l.state.condition != nil {
+		var err error
+		if takeover, err = l.state.condition.apply(req, takeover); err != nil {
+			return false, err
+		}
+	}
+	if l.state.claims {
+		// Renewing doesn't involve the claim so it is tried on its own first. An expired lock of
+		// this node is acquired through a transaction below like any other so claims are honored.
+		renewed, err := l.conditionalPut(ctx, key, req, fmt.Sprintf("%s AND :now <= %s", owned, expColumnName), now, exp)
+		if renewed || err != nil {
+			return renewed, err
+		}
+	}
+	// Conditions under which the lock is not held by anyone
+	unheld := []string{entryNotExist, takeover}
+	// Whether the key may be locked if it is not, and otherwise the lock item found
+	create, held := true, map[string]*dynamodb.AttributeValue(nil)
+	if ns, limit := l.state.quota.limit(key); limit > 0 {
+		var claim *dynamodb.TransactWriteItem
+		if l.state.claims {
+			claim = l.claimRelease(key)
+		}
+		acquired, cur, err := l.acquireCounted(ctx, key, ns, limit, req.Item, claim)
+		if err == errClaimed {
+			l.publish(ctx, EventDeny, key, now, 0, nil)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			l.publish(ctx, EventAcquire, key, now, exp, nil)
+			return true, nil
+		}
+		// Already locked. Renewing or taking over the lock leaves the count unchanged, while a
+		// lock released in the meantime must be counted again so it is not acquired here.
+		unheld = []string{takeover}
+		create, held = false, cur
+	}
+	if l.state.claims {
+		acquired, prev, err := l.acquireClaimed(ctx, key, req, create, held, takeover)
+		if err != nil && err != errClaimed {
+			return false, err
+		}
+		if !acquired {
+			l.publish(ctx, EventDeny, key, now, 0, nil)
+			return false, nil
+		}
+		l.publishLocked(ctx, key, now, exp, prev)
+		return true, nil
+	}
+	locked, err := l.conditionalPut(ctx, key, req, anyOf(append([]string{owned}, unheld...)), now, exp)
+	if err == nil && !locked {
+		// Locked is owned by someone else
+		l.publish(ctx, EventDeny, key, now, 0, nil)
+	}
+	return locked, err
+}
+
+// conditionalPut writes req's item if cond holds and publishes the resulting event. It returns
+// false if cond fails.
+func (l *Locker) conditionalPut(ctx context.Context, key string, req *dynamodb.PutItemInput, cond string, now, exp int64) (bool, error) {
+	r := *req
+	r.ConditionExpression = aws.String(cond)
+	r.ExpressionAttributeNames, r.ExpressionAttributeValues = usedAttributes(cond, req.ExpressionAttributeNames, req.ExpressionAttributeValues)
+	if l.state.audit != nil {
+		r.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	}
+	resp, err := l.state.db.PutItemWithContext(ctx, &r, l.requestOptions(ctx)...)
 	if err != nil {
 		if awserr, ok := err.(awserr.Error); ok {
 			if awserr.Code() == "ConditionalCheckFailedException" {
-				// Locked is owned by someone else
 				return false, nil
 			}
 		}
 		return false, err
 	}
+	l.publishLocked(ctx, key, now, exp, resp.Attributes)
 	return true, nil
 }
 
+// publishLocked publishes the acquisition, renewal or takeover of key that replaced prev.
+func (l *Locker) publishLocked(ctx context.Context, key string, now, exp int64, prev map[string]*dynamodb.AttributeValue) {
+	switch node := prev["nodeId"]; {
+	case node == nil:
+		l.publish(ctx, EventAcquire, key, now, exp, prev)
+	case aws.StringValue(node.S) == l.state.nodeID:
+		l.publish(ctx, EventRenew, key, now, exp, prev)
+	default:
+		l.publish(ctx, EventTakeover, key, now, exp, prev)
+	}
+}
+
 // Unlock removes the exclusive lock on this key.
-func (l *Locker) Unlock(ctx context.Context, key string) error {
+func (l *Locker) Unlock(ctx context.Context, key string, opts ...Option) error {
 	l.init.Do(l.getState)
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// The schema is not validated: a node must always be able to release the locks it holds
+	o := getOptions(opts)
+	if o.dryRun {
+		return l.dryUnlock(ctx, key)
+	}
+	if ns, limit := l.state.quota.limit(key); limit > 0 {
+		released, err := l.releaseCounted(ctx, key, ns)
+		if err != nil {
+			return err
+		}
+		if released {
+			l.state.outcomes.forget(key)
+			l.publish(ctx, EventRelease, key, millis(time.Now()), 0, nil)
+			return nil
+		}
+	}
 	entryNotExist := fmt.Sprintf("attribute_not_exists(%s)", l.state.tableKey)
 	owned := "nodeId = :nodeId"
 
@@ -125,12 +293,15 @@ func (l *Locker) Unlock(ctx context.Context, key string) error {
 		},
 		TableName: aws.String(l.state.tableName),
 	}
-	_, err := l.state.db.DeleteItemWithContext(ctx, req)
+	if l.state.audit != nil {
+		req.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	}
+	resp, err := l.state.db.DeleteItemWithContext(ctx, req, l.requestOptions(ctx)...)
 	if err != nil {
 		if awserr, ok := err.(awserr.Error); ok {
 			if awserr.Code() == "ConditionalCheckFailedException" {
 				// Either the lock didn't exist or it's owned by someone else
-				return fmt.Errorf("Key '%s' does not exist or is locked by another node.", key)
+				return errNotOwned(key)
 			} else {
 				return err
 			}
@@ -138,15 +309,49 @@ func (l *Locker) Unlock(ctx context.Context, key string) error {
 			return err
 		}
 	}
+	l.state.outcomes.forget(key)
+	if len(resp.Attributes) > 0 {
+		l.publish(ctx, EventRelease, key, millis(time.Now()), 0, resp.Attributes)
+	}
 	return nil
 }
 
+// ErrReservedKey is returned for keys that name the table's bookkeeping items.
+var ErrReservedKey = errors.New("reserved lock key")
+
+// checkKey rejects keys that would overwrite bookkeeping items other nodes rely on.
+func checkKey(key string) error {
+	if key == metaKey || strings.HasPrefix(key, quotaKeyPrefix) || strings.HasPrefix(key, claimKeyPrefix) {
+		return fmt.Errorf("key '%s': %w", key, ErrReservedKey)
+	}
+	return nil
+}
+
+func errNotOwned(key string) error {
+	return fmt.Errorf("Key '%s' does not exist or is locked by another node.", key)
+}
+
+// millis converts t to milliseconds since the Unix epoch, the unit stored in the lock table.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
 func (l *Locker) getState() {
 	s := &state{
-		tableName: l.TableName,
-		tableKey:  l.TableKey,
-		nodeID:    l.NodeID,
-		db:        l.DB,
+		tableName:      l.TableName,
+		tableKey:       l.TableKey,
+		nodeID:         l.NodeID,
+		db:             l.DB,
+		audit:          l.Audit,
+		condition:      l.Condition,
+		hook:           l.RequestHook,
+		quota:          l.Quota,
+		admit:          l.Admit,
+		claims:         l.Claims,
+		validateSchema: l.ValidateSchema,
+	}
+	if l.Contention != nil {
+		s.contention = newContention(*l.Contention)
 	}
 	if s.tableName == "" {
 		s.tableName = DefaultTableName
@@ -164,5 +369,8 @@ func (l *Locker) getState() {
 	if s.db == nil {
 		s.db = dynamodb.New(session.New())
 	}
+	if l.NodeMetadata {
+		s.nodeMeta = &metadataCache{}
+	}
 	l.state = s
 }
@@ -2,10 +2,12 @@ package lock
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -99,6 +101,43 @@ func getTestLock(respCode int, respBody string) (*Locker, *httptest.Server) {
 	}, ts
 }
 
+// getTestLockHandler returns a Locker whose DynamoDB requests are served by handler.
+func getTestLockHandler(handler http.HandlerFunc) (*Locker, *httptest.Server) {
+	ts := httptest.NewServer(handler)
+	conf := &aws.Config{
+		Endpoint:   &ts.URL,
+		MaxRetries: aws.Int(0),
+	}
+	db := dynamodb.New(session.New(), conf.WithRegion("us-west-2"))
+	return &Locker{
+		NodeID: "testNode12",
+		DB:     db,
+	}, ts
+}
+
+// getTxTestLock returns a Locker whose transactions are cancelled with the given reason codes,
+// or succeed if there are none. It also returns the operations called and the last PutItem request.
+func getTxTestLock(codes ...string) (*Locker, *httptest.Server, *[]string, *dynamodb.PutItemInput) {
+	var ops []string
+	put := &dynamodb.PutItemInput{}
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		ops = append(ops, op)
+		switch {
+		case op == "TransactWriteItems" && len(codes) > 0:
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",`+
+				`"Message":"Transaction cancelled","CancellationReasons":[{"Code":"%s"}]}`, strings.Join(codes, `"},{"Code":"`))
+		case op == "PutItem":
+			json.NewDecoder(r.Body).Decode(put)
+			fmt.Fprintln(w, "{}")
+		default:
+			fmt.Fprintln(w, "{}")
+		}
+	})
+	return lk, ts, &ops, put
+}
+
 func getHTTPResponse(code int, body string) (*httptest.Server, *http.Client) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(code)
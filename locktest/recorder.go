@@ -0,0 +1,71 @@
+// Package locktest provides utilities for testing code that uses package lock.
+package locktest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/leelynne/lock"
+)
+
+// Recorder is a lock.Publisher that keeps every event in memory so tests can assert on
+// a Locker's behavior, e.g. exactly one acquisition and two renewals:
+//
+//	rec := &locktest.Recorder{}
+//	locker.Audit = rec
+//	// exercise the code under test
+//	if rec.Count(lock.EventAcquire) != 1 || rec.Count(lock.EventRenew) != 2 {
+//		t.Errorf("unexpected lock activity %v", rec.Counts())
+//	}
+//
+// The zero value is ready to use and it is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	events []lock.Event
+}
+
+// Publish implements lock.Publisher.
+func (r *Recorder) Publish(ctx context.Context, e *lock.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, *e)
+	return nil
+}
+
+// Events returns the recorded events in the order they were published.
+func (r *Recorder) Events() []lock.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]lock.Event(nil), r.events...)
+}
+
+// Count returns the number of recorded events of the given type.
+func (r *Recorder) Count(typ string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+// Counts returns the number of recorded events by type.
+func (r *Recorder) Counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := map[string]int{}
+	for _, e := range r.events {
+		counts[e.Type]++
+	}
+	return counts
+}
+
+// Reset forgets all recorded events.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
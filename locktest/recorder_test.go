@@ -0,0 +1,38 @@
+package locktest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/leelynne/lock"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := &Recorder{}
+	var _ lock.Publisher = rec
+	ctx := context.Background()
+
+	rec.Publish(ctx, &lock.Event{Type: lock.EventAcquire, Key: "a"})
+	rec.Publish(ctx, &lock.Event{Type: lock.EventRenew, Key: "a"})
+	rec.Publish(ctx, &lock.Event{Type: lock.EventRenew, Key: "a"})
+
+	if n := rec.Count(lock.EventAcquire); n != 1 {
+		t.Errorf("Expected 1 acquisition got %d", n)
+	}
+	if n := rec.Count(lock.EventRelease); n != 0 {
+		t.Errorf("Expected no releases got %d", n)
+	}
+	expected := map[string]int{lock.EventAcquire: 1, lock.EventRenew: 2}
+	if counts := rec.Counts(); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected %v got %v", expected, counts)
+	}
+	if events := rec.Events(); len(events) != 3 || events[0].Type != lock.EventAcquire {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	rec.Reset()
+	if len(rec.Events()) != 0 {
+		t.Error("Expected no events after Reset")
+	}
+}
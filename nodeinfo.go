@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Lock item attributes written when Locker.NodeMetadata is enabled so lock holders can
+// be located operationally. Discovery happens once, during the Locker's first Lock call,
+// and may add up to a couple of seconds to it when not running on AWS. Attributes whose
+// value could not be discovered are not written.
+const (
+	ECSTaskARNAttribute    = "node_ecs_task_arn"
+	EC2InstanceIDAttribute = "node_ec2_instance_id"
+	K8sPodAttribute        = "node_k8s_pod"
+	AZAttribute            = "node_az"
+)
+
+// metadataTimeout bounds how long node metadata discovery may take.
+const metadataTimeout = 2 * time.Second
+
+// metadataCache discovers the node metadata on first use and remembers it.
+type metadataCache struct {
+	mu    sync.Mutex
+	done  bool
+	attrs map[string]*dynamodb.AttributeValue
+}
+
+// get returns the node metadata, discovering it with ctx if needed. If ctx is done before
+// discovery finishes whatever was found is returned and discovery is repeated on the next call.
+func (m *metadataCache) get(ctx context.Context) map[string]*dynamodb.AttributeValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done {
+		return m.attrs
+	}
+	attrs := nodeMetadata(ctx, ec2metadata.New(session.New()))
+	if ctx.Err() == nil {
+		m.done, m.attrs = true, attrs
+	}
+	return attrs
+}
+
+// nodeMetadata discovers where this node runs from the environment, the ECS task metadata
+// endpoint and EC2 instance metadata. imds may be nil to skip EC2 instance metadata.
+func nodeMetadata(ctx context.Context, imds *ec2metadata.EC2Metadata) map[string]*dynamodb.AttributeValue {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	meta := map[string]string{}
+
+	// Kubernetes: POD_NAME is conventionally set from the downward API, otherwise
+	// the pod's hostname is its name.
+	if pod := os.Getenv("POD_NAME"); pod != "" {
+		meta[K8sPodAttribute] = pod
+	} else if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		if name, err := os.Hostname(); err == nil {
+			meta[K8sPodAttribute] = name
+		}
+	}
+
+	if uri := os.Getenv("ECS_CONTAINER_METADATA_URI_V4"); uri != "" {
+		if task, err := ecsTaskMetadata(ctx, uri); err == nil {
+			meta[ECSTaskARNAttribute] = task.TaskARN
+			meta[AZAttribute] = task.AvailabilityZone
+		}
+	}
+
+	if imds != nil {
+		if doc, err := imds.GetInstanceIdentityDocumentWithContext(ctx); err == nil {
+			meta[EC2InstanceIDAttribute] = doc.InstanceID
+			meta[AZAttribute] = doc.AvailabilityZone
+		}
+	}
+
+	attrs := map[string]*dynamodb.AttributeValue{}
+	for k, v := range meta {
+		if v != "" {
+			attrs[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+	}
+	return attrs
+}
+
+type ecsTask struct {
+	TaskARN          string
+	AvailabilityZone string
+}
+
+func ecsTaskMetadata(ctx context.Context, uri string) (*ecsTask, error) {
+	req, err := http.NewRequest(http.MethodGet, uri+"/task", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ECS task metadata returned status %d", resp.StatusCode)
+	}
+	task := &ecsTask{}
+	if err := json.NewDecoder(resp.Body).Decode(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
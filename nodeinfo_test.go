@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestNodeMetadata(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/task", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"TaskARN":"arn:aws:ecs:us-west-2:123:task/abc","AvailabilityZone":"us-west-2a"}`)
+	})
+	mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+		fmt.Fprint(w, "token")
+	})
+	mux.HandleFunc("/latest/dynamic/instance-identity/document", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"instanceId":"i-0123","availabilityZone":"us-west-2b"}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	defer setenv("POD_NAME", "worker-7f9c")()
+	defer setenv("ECS_CONTAINER_METADATA_URI_V4", ts.URL)()
+	// Commonly set in CI, it makes the client fail without calling the test server
+	defer setenv("AWS_EC2_METADATA_DISABLED", "false")()
+
+	imds := ec2metadata.New(session.New(), &aws.Config{Endpoint: aws.String(ts.URL + "/latest")})
+	meta := nodeMetadata(context.Background(), imds)
+
+	expected := map[string]string{
+		K8sPodAttribute:        "worker-7f9c",
+		ECSTaskARNAttribute:    "arn:aws:ecs:us-west-2:123:task/abc",
+		EC2InstanceIDAttribute: "i-0123",
+		AZAttribute:            "us-west-2b",
+	}
+	for k, v := range expected {
+		if meta[k] == nil {
+			t.Errorf("Expected %s to be '%s' got nothing", k, v)
+		} else if got := aws.StringValue(meta[k].S); got != v {
+			t.Errorf("Expected %s to be '%s' got '%s'", k, v, got)
+		}
+	}
+}
+
+func TestNodeMetadataNone(t *testing.T) {
+	for _, env := range []string{"POD_NAME", "KUBERNETES_SERVICE_HOST", "ECS_CONTAINER_METADATA_URI_V4"} {
+		if os.Getenv(env) != "" {
+			t.Skipf("Running with %s set", env)
+		}
+	}
+	meta := nodeMetadata(context.Background(), nil)
+	if len(meta) != 0 {
+		t.Errorf("Expected no metadata outside of ECS/EC2/Kubernetes got %v", meta)
+	}
+}
+
+// setenv sets an environment variable and returns a func restoring its previous value.
+func setenv(key, value string) func() {
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestNodeMetadataLazy(t *testing.T) {
+	lk := &Locker{NodeID: "testNode12", NodeMetadata: true}
+	lk.ShouldSkip("mylock")
+	lk.Config()
+	if lk.state.nodeMeta.done {
+		t.Error("Expected discovery to wait for the first Lock call")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lk.state.nodeMeta.get(ctx)
+	if lk.state.nodeMeta.done {
+		t.Error("Expected discovery cut short by the caller to be repeated")
+	}
+}
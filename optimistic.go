@@ -0,0 +1,99 @@
+package lock
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DefaultVersionAttribute is the attribute OptimisticLock stores item versions in.
+const DefaultVersionAttribute = "version"
+
+// OptimisticLock performs version based compare-and-swap writes on arbitrary items.
+// It is useful when a full lease is overkill: instead of holding a lock, a writer reads
+// an item, modifies it and writes it back only if nobody else has written it since.
+//
+// Usage:
+//
+//	ol := &lock.OptimisticLock{Locker: locker, TableName: "accounts"}
+//	item, version, err := ol.Get(ctx, key)
+//	// modify item
+//	swapped, err := ol.Put(ctx, item, version)
+type OptimisticLock struct {
+	Locker           *Locker // Supplies the DynamoDB client
+	TableName        string  // Dynamo table holding the items
+	VersionAttribute string  // Numeric version attribute name. Defaults to "version"
+}
+
+// Get does a consistent read of the item with the given key and returns it with its version.
+// A missing item is returned as nil with version 0.
+func (o *OptimisticLock) Get(ctx context.Context, key map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, int64, error) {
+	o.Locker.init.Do(o.Locker.getState)
+	resp, err := o.Locker.state.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+		TableName:      aws.String(o.TableName),
+	}, o.Locker.requestOptions(ctx)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Item) == 0 {
+		return nil, 0, nil
+	}
+	var version int64
+	if v, ok := resp.Item[o.versionAttribute()]; ok && v.N != nil {
+		version, err = strconv.ParseInt(*v.N, 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return resp.Item, version, nil
+}
+
+// Put writes item if the stored version still equals version, storing it with version+1.
+// Use version 0 to create an item that must not exist yet.
+// Put returns false if another writer changed the item first. A non-nil error means nothing was written.
+func (o *OptimisticLock) Put(ctx context.Context, item map[string]*dynamodb.AttributeValue, version int64) (swapped bool, e error) {
+	o.Locker.init.Do(o.Locker.getState)
+	attr := o.versionAttribute()
+
+	newItem := make(map[string]*dynamodb.AttributeValue, len(item)+1)
+	for k, v := range item {
+		newItem[k] = v
+	}
+	newItem[attr] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(version+1, 10))}
+	req := &dynamodb.PutItemInput{
+		Item:                     newItem,
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String(attr)},
+		TableName:                aws.String(o.TableName),
+	}
+	if version == 0 {
+		req.ConditionExpression = aws.String("attribute_not_exists(#version)")
+	} else {
+		req.ConditionExpression = aws.String("#version = :version")
+		req.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.FormatInt(version, 10))},
+		}
+	}
+	_, err := o.Locker.state.db.PutItemWithContext(ctx, req, o.Locker.requestOptions(ctx)...)
+	if err != nil {
+		if awserr, ok := err.(awserr.Error); ok {
+			if awserr.Code() == "ConditionalCheckFailedException" {
+				// Someone else wrote the item since it was read
+				return false, nil
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (o *OptimisticLock) versionAttribute() string {
+	if o.VersionAttribute == "" {
+		return DefaultVersionAttribute
+	}
+	return o.VersionAttribute
+}
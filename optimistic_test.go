@@ -0,0 +1,60 @@
+package lock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var testItemKey = map[string]*dynamodb.AttributeValue{"id": {S: aws.String("account1")}}
+
+func TestOptimisticGet(t *testing.T) {
+	lk, ts := getTestLock(200, `{"Item":{"id":{"S":"account1"},"version":{"N":"7"}}}`)
+	defer ts.Close()
+	ol := &OptimisticLock{Locker: lk, TableName: "accounts"}
+
+	item, version, err := ol.Get(context.Background(), testItemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 7 {
+		t.Errorf("Expected version 7 got %d", version)
+	}
+	if aws.StringValue(item["id"].S) != "account1" {
+		t.Errorf("Unexpected item %v", item)
+	}
+}
+
+func TestOptimisticPutSuccess(t *testing.T) {
+	lk, ts := getTestLock(200, "{}")
+	defer ts.Close()
+	ol := &OptimisticLock{Locker: lk, TableName: "accounts"}
+
+	swapped, err := ol.Put(context.Background(), testItemKey, 7)
+	if err != nil {
+		t.Error(err)
+	}
+	if !swapped {
+		t.Error("failed to swap")
+	}
+	if _, ok := testItemKey[DefaultVersionAttribute]; ok {
+		t.Error("Put must not modify the caller's item")
+	}
+}
+
+func TestOptimisticPutConflict(t *testing.T) {
+	lk, ts := getTestLock(400,
+		`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+	defer ts.Close()
+	ol := &OptimisticLock{Locker: lk, TableName: "accounts"}
+
+	swapped, err := ol.Put(context.Background(), testItemKey, 7)
+	if err != nil {
+		t.Error("Expect no error when another writer won the swap")
+	}
+	if swapped {
+		t.Error("Should not have swapped")
+	}
+}
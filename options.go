@@ -0,0 +1,45 @@
+package lock
+
+import "errors"
+
+// Option modifies a single Lock or Unlock call.
+type Option func(*options)
+
+type options struct {
+	token  string
+	dryRun bool
+}
+
+// WithIdempotencyToken marks a Lock call as a retry of any earlier call for the same key and
+// expiration with the same token. While the expiration has not passed the earlier call's outcome
+// is returned again without another conditional write to the table. Calls that returned an
+// error are not remembered and are retried normally, and unlocking the key forgets the outcomes
+// of every earlier call for it.
+func WithIdempotencyToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// ErrConditionNotEvaluated is returned by a dry run Lock call whose outcome depends on the
+// Locker's AttributeCondition, i.e. taking over another node's expired lock.
+var ErrConditionNotEvaluated = errors.New("dry run cannot evaluate the lock's AttributeCondition")
+
+// WithDryRun reports whether a Lock or Unlock call would succeed without writing to the table.
+// The current lock entry is fetched with a consistent read and the lock's conditions are
+// evaluated locally. A Locker's AttributeCondition cannot be evaluated locally, so when a dry
+// run would have to take over an expired lock under it Lock returns false and
+// ErrConditionNotEvaluated.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+func getOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,133 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Defaults for unset Planner fields.
+const (
+	DefaultPlannerBackoff    = 100 * time.Millisecond
+	DefaultPlannerMaxBackoff = 10 * time.Second
+)
+
+// Planner acquires locks on a large set of keys. Instead of blocking on each key in turn it
+// interleaves attempts across all keys that are due, backs off contended keys exponentially
+// and never retries a key before the Locker's RetryAfter hint for it has passed.
+//
+// Locks acquired by the Planner are not renewed while it works on the remaining keys, so TTL
+// must cover the time it takes to acquire them all.
+type Planner struct {
+	Locker     *Locker
+	Keys       []string
+	TTL        time.Duration  // How long each lock is held from the moment it is acquired
+	Backoff    time.Duration  // First backoff of a contended key. Defaults to DefaultPlannerBackoff
+	MaxBackoff time.Duration  // Defaults to DefaultPlannerMaxBackoff
+	Progress   func(Progress) // Optional, called after every attempt
+}
+
+// Progress reports the outcome of one Planner attempt.
+type Progress struct {
+	Key      string
+	Locked   bool
+	Err      error // Error returned by Lock, if any
+	Acquired int   // Keys locked so far
+	Total    int
+}
+
+// Run attempts to lock every key until all are locked or ctx is done. Keys held by other nodes
+// and keys whose Lock call fails with a retryable error, e.g. throttling, are retried. A key
+// whose Lock call fails with any other error, e.g. ErrQuotaExceeded or an AdmissionHook
+// rejection, is given up and reported through Progress. Run returns the keys it
+// locked, in the order they were acquired, and ctx's error if it is done or else the first
+// error of the keys it gave up, if any.
+func (p *Planner) Run(ctx context.Context) ([]string, error) {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultPlannerBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultPlannerMaxBackoff
+	}
+	type pending struct {
+		key     string
+		next    time.Time
+		backoff time.Duration
+	}
+	queue := make([]*pending, len(p.Keys))
+	for i, key := range p.Keys {
+		queue[i] = &pending{key: key, backoff: backoff}
+	}
+	var locked []string
+	var failed error
+
+	for len(queue) > 0 {
+		now := time.Now()
+		wake := now.Add(maxBackoff)
+		remaining := queue[:0]
+		for _, k := range queue {
+			if now.Before(k.next) {
+				remaining = append(remaining, k)
+				if k.next.Before(wake) {
+					wake = k.next
+				}
+				continue
+			}
+			ok, err := p.Locker.Lock(ctx, k.key, time.Now().Add(p.TTL))
+			switch {
+			case ok:
+				locked = append(locked, k.key)
+			case ctx.Err() != nil:
+				return locked, ctx.Err()
+			case err != nil && !retryable(err):
+				// Retrying won't help
+				if failed == nil {
+					failed = fmt.Errorf("key '%s': %w", k.key, err)
+				}
+			default:
+				// Held by another node, throttled or a transient failure
+				k.next = time.Now().Add(k.backoff)
+				if hint := time.Now().Add(p.Locker.RetryAfter(k.key)); hint.After(k.next) {
+					k.next = hint
+				}
+				if k.backoff *= 2; k.backoff > maxBackoff {
+					k.backoff = maxBackoff
+				}
+				remaining = append(remaining, k)
+				if k.next.Before(wake) {
+					wake = k.next
+				}
+			}
+			if p.Progress != nil {
+				p.Progress(Progress{Key: k.key, Locked: ok, Err: err, Acquired: len(locked), Total: len(p.Keys)})
+			}
+		}
+		queue = remaining
+		if len(queue) == 0 {
+			break
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return locked, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return locked, failed
+}
+
+// retryable reports whether err is an AWS error worth retrying, e.g. throttling. The SDK
+// considers every error it doesn't know retryable, which would retry AdmissionHook rejections.
+func retryable(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	return request.IsErrorRetryable(aerr) || request.IsErrorThrottle(aerr)
+}
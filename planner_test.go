@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// getPlannerTestLock returns a Locker for which each key is contended for the given number of attempts.
+func getPlannerTestLock(contended map[string]int) (*Locker, func()) {
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		var req dynamodb.PutItemInput
+		json.NewDecoder(r.Body).Decode(&req)
+		key := aws.StringValue(req.Item[DefaultTableKey].S)
+		if contended[key] > 0 {
+			contended[key]--
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+			return
+		}
+		fmt.Fprintln(w, "{}")
+	})
+	return lk, ts.Close
+}
+
+func TestPlanner(t *testing.T) {
+	lk, done := getPlannerTestLock(map[string]int{"a": 2})
+	defer done()
+	var progress []Progress
+	p := &Planner{
+		Locker:   lk,
+		Keys:     []string{"a", "b", "c"},
+		TTL:      time.Minute,
+		Backoff:  time.Millisecond,
+		Progress: func(pr Progress) { progress = append(progress, pr) },
+	}
+
+	locked, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The contended key must not hold up the others
+	if expected := []string{"b", "c", "a"}; !reflect.DeepEqual(locked, expected) {
+		t.Errorf("Expected %v got %v", expected, locked)
+	}
+	if len(progress) != 5 {
+		t.Fatalf("Expected 5 attempts got %d", len(progress))
+	}
+	if last := progress[4]; last.Key != "a" || !last.Locked || last.Acquired != 3 || last.Total != 3 {
+		t.Errorf("Unexpected final progress %+v", last)
+	}
+}
+
+func TestPlannerRetryAfter(t *testing.T) {
+	lk, done := getPlannerTestLock(map[string]int{"a": 1})
+	defer done()
+	lk.Contention = &ContentionPolicy{Threshold: 1, CoolDown: 50 * time.Millisecond}
+	p := &Planner{Locker: lk, Keys: []string{"a"}, TTL: time.Minute, Backoff: time.Millisecond}
+
+	start := time.Now()
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the retry to wait for the cool down, took %s", elapsed)
+	}
+}
+
+func TestPlannerError(t *testing.T) {
+	lk, done := getPlannerTestLock(map[string]int{"b": 1})
+	defer done()
+	denied := errors.New("denied")
+	lk.Admit = func(key, nodeID string, ttl time.Duration) error {
+		if key == "a" {
+			return denied
+		}
+		return nil
+	}
+	var progress []Progress
+	p := &Planner{
+		Locker:   lk,
+		Keys:     []string{"a", "b"},
+		TTL:      time.Minute,
+		Backoff:  time.Millisecond,
+		Progress: func(pr Progress) { progress = append(progress, pr) },
+	}
+
+	// Retrying the rejected key would never return
+	locked, err := p.Run(context.Background())
+	if !errors.Is(err, denied) {
+		t.Errorf("Expected the rejection got %v", err)
+	}
+	if !reflect.DeepEqual(locked, []string{"b"}) {
+		t.Errorf("Expected the other keys to be locked got %v", locked)
+	}
+	if len(progress) != 3 || progress[0].Key != "a" || progress[0].Err != denied {
+		t.Errorf("Expected a single attempt at the rejected key got %+v", progress)
+	}
+}
+
+func TestPlannerThrottled(t *testing.T) {
+	throttled := false
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		if !throttled {
+			throttled = true
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"Rate exceeded"}`)
+			return
+		}
+		fmt.Fprintln(w, "{}")
+	})
+	defer ts.Close()
+	p := &Planner{Locker: lk, Keys: []string{"a"}, TTL: time.Minute, Backoff: time.Millisecond}
+
+	locked, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locked, []string{"a"}) {
+		t.Errorf("Expected the throttled key to be retried got %v", locked)
+	}
+}
+
+func TestPlannerCancel(t *testing.T) {
+	lk, done := getPlannerTestLock(map[string]int{"a": 1000})
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p := &Planner{Locker: lk, Keys: []string{"a", "b"}, TTL: time.Minute, Backoff: time.Millisecond}
+
+	locked, err := p.Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the context's error got %v", err)
+	}
+	if !reflect.DeepEqual(locked, []string{"b"}) {
+		t.Errorf("Expected only 'b' to be locked got %v", locked)
+	}
+}
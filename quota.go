@@ -0,0 +1,183 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrQuotaExceeded is returned by Lock when acquiring a new lock would exceed its namespace's quota.
+var ErrQuotaExceeded = errors.New("lock quota exceeded")
+
+const (
+	quotaKeyPrefix   = "_quota:"    // Key prefix of the per namespace counter items
+	quotaCountColumn = "lock_count" // Counter attribute of the counter items
+)
+
+// Quota limits how many locks each namespace may hold at once in a shared lock table so one
+// team's runaway automation can't consume all of its capacity.
+//
+// Counts are kept in "_quota:<namespace>" items in the lock table itself. A namespace's count
+// goes up when a node locks a key that was not locked and down when a node unlocks a key it
+// holds; renewing or taking over an expired lock does not change it. Quotas are soft: an
+// expired lock that is never unlocked or taken over keeps counting against its namespace.
+type Quota struct {
+	// Namespace maps a key to its namespace. Defaults to the part of the key before the
+	// first ':'. Keys in the "" namespace are not subject to quotas.
+	Namespace func(key string) string
+	Limits    map[string]int64 // Per namespace limits
+	Default   int64            // Limit for namespaces not in Limits. Zero means unlimited
+}
+
+// limit returns key's namespace and its limit, zero meaning unlimited. q may be nil.
+func (q *Quota) limit(key string) (string, int64) {
+	if q == nil {
+		return "", 0
+	}
+	var ns string
+	if q.Namespace != nil {
+		ns = q.Namespace(key)
+	} else if i := strings.Index(key, ":"); i > 0 {
+		ns = key[:i]
+	}
+	if ns == "" {
+		return "", 0
+	}
+	if limit, ok := q.Limits[ns]; ok {
+		return ns, limit
+	}
+	return ns, q.Default
+}
+
+// acquireCounted writes item if key is not locked at all and increments its namespace's count
+// in the same transaction. It returns false and the current lock item if key is already locked,
+// by any node. If claim is not nil it is added to the transaction and errClaimed is returned if
+// it fails.
+func (l *Locker) acquireCounted(ctx context.Context, key, ns string, limit int64, item map[string]*dynamodb.AttributeValue, claim *dynamodb.TransactWriteItem) (bool, map[string]*dynamodb.AttributeValue, error) {
+	req := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					Item:                                item,
+					ConditionExpression:                 aws.String("attribute_not_exists(#key)"),
+					ExpressionAttributeNames:            map[string]*string{"#key": aws.String(l.state.tableKey)},
+					ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+					TableName:                           aws.String(l.state.tableName),
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					Key:                 l.quotaKey(ns),
+					UpdateExpression:    aws.String(fmt.Sprintf("SET %s = if_not_exists(%s, :zero) + :one", quotaCountColumn, quotaCountColumn)),
+					ConditionExpression: aws.String(fmt.Sprintf("attribute_not_exists(%s) OR %s < :limit", quotaCountColumn, quotaCountColumn)),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":zero":  {N: aws.String("0")},
+						":one":   {N: aws.String("1")},
+						":limit": {N: aws.String(strconv.FormatInt(limit, 10))},
+					},
+					TableName: aws.String(l.state.tableName),
+				},
+			},
+		},
+	}
+	if claim != nil {
+		req.TransactItems = append(req.TransactItems, claim)
+	}
+	_, err := l.state.db.TransactWriteItemsWithContext(ctx, req, l.requestOptions(ctx)...)
+	if err != nil {
+		if codes, ok := cancellationCodes(err); ok {
+			if codes[0] == "ConditionalCheckFailed" {
+				// Already locked
+				return false, cancelledItem(err, 0), nil
+			}
+			if len(codes) > 2 && codes[2] == "ConditionalCheckFailed" {
+				return false, nil, errClaimed
+			}
+			if len(codes) > 1 && codes[1] == "ConditionalCheckFailed" {
+				return false, nil, fmt.Errorf("namespace '%s': %w", ns, ErrQuotaExceeded)
+			}
+		}
+		return false, nil, err
+	}
+	return true, nil, nil
+}
+
+// releaseCounted deletes key if this node holds it and decrements its namespace's count in the
+// same transaction. It returns false if nothing was released, e.g. because key is not locked,
+// is locked by another node or the count is already zero.
+func (l *Locker) releaseCounted(ctx context.Context, key, ns string) (bool, error) {
+	dynamoKey := map[string]*dynamodb.AttributeValue{}
+	dynamoKey[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(key)}
+	req := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					Key:                 dynamoKey,
+					ConditionExpression: aws.String("nodeId = :nodeId"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":nodeId": {S: aws.String(l.state.nodeID)},
+					},
+					TableName: aws.String(l.state.tableName),
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					Key:                 l.quotaKey(ns),
+					UpdateExpression:    aws.String(fmt.Sprintf("SET %s = %s - :one", quotaCountColumn, quotaCountColumn)),
+					ConditionExpression: aws.String(fmt.Sprintf("%s > :zero", quotaCountColumn)),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":zero": {N: aws.String("0")},
+						":one":  {N: aws.String("1")},
+					},
+					TableName: aws.String(l.state.tableName),
+				},
+			},
+		},
+	}
+	_, err := l.state.db.TransactWriteItemsWithContext(ctx, req, l.requestOptions(ctx)...)
+	if err != nil {
+		if codes, ok := cancellationCodes(err); ok {
+			for _, code := range codes {
+				if code == "ConditionalCheckFailed" {
+					return false, nil
+				}
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Locker) quotaKey(ns string) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{}
+	key[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(quotaKeyPrefix + ns)}
+	return key
+}
+
+// cancellationCodes returns the per item cancellation reason codes of a cancelled transaction.
+func cancellationCodes(err error) ([]string, bool) {
+	canceled, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok || len(canceled.CancellationReasons) == 0 {
+		return nil, false
+	}
+	codes := make([]string, len(canceled.CancellationReasons))
+	for i, r := range canceled.CancellationReasons {
+		codes[i] = aws.StringValue(r.Code)
+	}
+	return codes, true
+}
+
+// cancelledItem returns the item the i-th condition of a cancelled transaction was checked
+// against, if it was requested with ReturnValuesOnConditionCheckFailure and existed.
+func cancelledItem(err error, i int) map[string]*dynamodb.AttributeValue {
+	canceled, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok || i >= len(canceled.CancellationReasons) {
+		return nil
+	}
+	return canceled.CancellationReasons[i].Item
+}
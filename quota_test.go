@@ -0,0 +1,134 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestQuotaAcquire(t *testing.T) {
+	lk, ts, ops, _ := getTxTestLock()
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	locked, err := lk.Lock(context.Background(), "team:job1", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to lock - %v", err)
+	}
+	if strings.Join(*ops, ",") != "TransactWriteItems" {
+		t.Errorf("Expected a single counted acquisition got %v", *ops)
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	lk, ts, _, _ := getTxTestLock("None", "ConditionalCheckFailed")
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	locked, err := lk.Lock(context.Background(), "team:job1", time.Now().Add(10*time.Minute))
+	if locked {
+		t.Error("Should not have acquired the lock")
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded got %v", err)
+	}
+}
+
+func TestQuotaAlreadyLocked(t *testing.T) {
+	lk, ts, ops, put := getTxTestLock("ConditionalCheckFailed", "None")
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	locked, err := lk.Lock(context.Background(), "team:job1", time.Now().Add(10*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("failed to renew - %v", err)
+	}
+	if strings.Join(*ops, ",") != "TransactWriteItems,PutItem" {
+		t.Errorf("Expected renewal to fall back to a plain put got %v", *ops)
+	}
+	if strings.Contains(aws.StringValue(put.ConditionExpression), "attribute_not_exists") {
+		t.Errorf("Uncounted put must not create new locks: %s", aws.StringValue(put.ConditionExpression))
+	}
+}
+
+func TestQuotaNoNamespace(t *testing.T) {
+	lk, ts, ops, _ := getTxTestLock()
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	if _, err := lk.Lock(context.Background(), "job1", time.Now().Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ops, ",") != "PutItem" {
+		t.Errorf("Expected keys without a namespace to skip quotas got %v", *ops)
+	}
+}
+
+func TestQuotaRelease(t *testing.T) {
+	lk, ts, ops, _ := getTxTestLock()
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	if err := lk.Unlock(context.Background(), "team:job1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ops, ",") != "TransactWriteItems" {
+		t.Errorf("Expected a single counted release got %v", *ops)
+	}
+}
+
+func TestQuotaReleaseNotHeld(t *testing.T) {
+	lk, ts, ops, _ := getTxTestLock("ConditionalCheckFailed", "None")
+	defer ts.Close()
+	lk.Quota = &Quota{Default: 2}
+
+	if err := lk.Unlock(context.Background(), "team:job1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ops, ",") != "TransactWriteItems,DeleteItem" {
+		t.Errorf("Expected release to fall back to a plain delete got %v", *ops)
+	}
+}
+
+func TestQuotaNamespace(t *testing.T) {
+	q := &Quota{Limits: map[string]int64{"team": 5}}
+	if ns, limit := q.limit("team:job1"); ns != "team" || limit != 5 {
+		t.Errorf("Unexpected namespace %s limit %d", ns, limit)
+	}
+	if _, limit := q.limit("other:job1"); limit != 0 {
+		t.Errorf("Expected namespaces without a limit to be unlimited got %d", limit)
+	}
+	q.Namespace = func(key string) string { return "all" }
+	q.Default = 3
+	if ns, limit := q.limit("job1"); ns != "all" || limit != 3 {
+		t.Errorf("Unexpected namespace %s limit %d", ns, limit)
+	}
+}
+
+func TestReservedKeys(t *testing.T) {
+	lk, ts, ops, _ := getTxTestLock()
+	defer ts.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"_quota:team", "_claim:job1", "_meta"} {
+		if _, err := lk.Lock(ctx, key, time.Now().Add(time.Minute)); !errors.Is(err, ErrReservedKey) {
+			t.Errorf("Lock %s: expected ErrReservedKey got %v", key, err)
+		}
+		if err := lk.Unlock(ctx, key); !errors.Is(err, ErrReservedKey) {
+			t.Errorf("Unlock %s: expected ErrReservedKey got %v", key, err)
+		}
+		if _, err := lk.Claim(ctx, key, time.Now().Add(time.Minute)); !errors.Is(err, ErrReservedKey) {
+			t.Errorf("Claim %s: expected ErrReservedKey got %v", key, err)
+		}
+	}
+	if len(*ops) != 0 {
+		t.Errorf("Expected reserved keys to be rejected before any request got %v", *ops)
+	}
+	if _, err := lk.Lock(ctx, "_metadata", time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("Only the reserved keys should be rejected got %v", err)
+	}
+}
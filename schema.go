@@ -0,0 +1,156 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SchemaVersion is the version of the lock table's item layout written by this package.
+const SchemaVersion = 1
+
+// ErrIncompatibleSchema is returned by Lock and Claim when a Locker with ValidateSchema enabled
+// finds that the lock table's "_meta" item describes TableSettings that differ from its own.
+var ErrIncompatibleSchema = errors.New("incompatible lock table schema")
+
+const (
+	metaKey       = "_meta" // Key of the item describing the table's schema
+	timestampUnit = "ms"    // Unit of the expirations stored in the table
+)
+
+// TableSettings are the settings that determine the layout of a lock table's items. Every node
+// sharing a table has to agree on them: e.g. a node that doesn't count quotas or honor claims
+// silently breaks them for every other node. Settings that only affect a node's own decisions,
+// such as quota limits, are not included so they can be changed with a rolling deploy.
+type TableSettings struct {
+	SchemaVersion int    `json:"schema_version"`
+	TimestampUnit string `json:"timestamp_unit"`
+	TableKey      string `json:"table_key"`
+	Claims        bool   `json:"claims"`
+	Quota         bool   `json:"quota"`
+}
+
+// TableSettings returns the table settings of a Locker with configuration c.
+func (c Config) TableSettings() TableSettings {
+	return TableSettings{
+		SchemaVersion: SchemaVersion,
+		TimestampUnit: timestampUnit,
+		TableKey:      c.TableKey,
+		Claims:        c.Claims,
+		Quota:         c.Quota != nil,
+	}
+}
+
+// Diff describes every setting that differs between s and other as "Field: s's value != other's value".
+func (s TableSettings) Diff(other TableSettings) []string {
+	return diffFields(s, other, "")
+}
+
+type schemaCheck struct {
+	mu sync.Mutex
+	ok bool
+}
+
+// WriteSchema records this Locker's TableSettings in the table's "_meta" item, replacing any
+// existing ones. With ValidateSchema enabled, nodes refuse to lock keys in a table whose "_meta"
+// item differs from their own settings. A change to the table settings, e.g. enabling claims,
+// can't be rolled out while nodes with the old settings still use the table. To migrate, stop
+// the old nodes, call WriteSchema from a node with the new settings and then start the others.
+func (l *Locker) WriteSchema(ctx context.Context) error {
+	l.init.Do(l.getState)
+	s := &l.state.schema
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := l.putSchema(ctx, false); err != nil {
+		return err
+	}
+	s.ok = true
+	return nil
+}
+
+// validateSchema makes sure the table's "_meta" item matches this Locker's TableSettings,
+// creating it if the table has none. Once validated the table is not checked again. If write is
+// false a missing "_meta" item is not created and the check is repeated on the next call.
+func (l *Locker) validateSchema(ctx context.Context, write bool) error {
+	if !l.state.validateSchema {
+		return nil
+	}
+	s := &l.state.schema
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ok {
+		return nil
+	}
+
+	if write {
+		err := l.putSchema(ctx, true)
+		if err == nil {
+			s.ok = true
+			return nil
+		}
+		if awserr, ok := err.(awserr.Error); !ok || awserr.Code() != "ConditionalCheckFailedException" {
+			return err
+		}
+		// Another node already described the table
+	}
+
+	item, err := l.getItem(ctx, metaKey)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return nil
+	}
+	version, err := numberAttr(item, "schema_version")
+	if err != nil {
+		return err
+	}
+	other := TableSettings{
+		SchemaVersion: int(version),
+		TimestampUnit: stringAttr(item, "timestamp_unit"),
+		TableKey:      stringAttr(item, "table_key"),
+		Claims:        boolAttr(item, "claims"),
+		Quota:         boolAttr(item, "quota"),
+	}
+	if diffs := l.Config().TableSettings().Diff(other); len(diffs) > 0 {
+		return fmt.Errorf("table '%s' %s: %w", l.state.tableName, strings.Join(diffs, ", "), ErrIncompatibleSchema)
+	}
+	s.ok = true
+	return nil
+}
+
+// putSchema writes the "_meta" item, only if the table has none if create is true.
+func (l *Locker) putSchema(ctx context.Context, create bool) error {
+	settings := l.Config().TableSettings()
+	item := map[string]*dynamodb.AttributeValue{}
+	item[l.state.tableKey] = &dynamodb.AttributeValue{S: aws.String(metaKey)}
+	item["schema_version"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(settings.SchemaVersion))}
+	item["timestamp_unit"] = &dynamodb.AttributeValue{S: aws.String(settings.TimestampUnit)}
+	item["table_key"] = &dynamodb.AttributeValue{S: aws.String(settings.TableKey)}
+	item["claims"] = &dynamodb.AttributeValue{BOOL: aws.Bool(settings.Claims)}
+	item["quota"] = &dynamodb.AttributeValue{BOOL: aws.Bool(settings.Quota)}
+	req := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(l.state.tableName),
+	}
+	if create {
+		req.ConditionExpression = aws.String("attribute_not_exists(#key)")
+		req.ExpressionAttributeNames = map[string]*string{"#key": aws.String(l.state.tableKey)}
+	}
+	_, err := l.state.db.PutItemWithContext(ctx, req, l.requestOptions(ctx)...)
+	return err
+}
+
+func boolAttr(item map[string]*dynamodb.AttributeValue, name string) bool {
+	if v, ok := item[name]; ok {
+		return aws.BoolValue(v.BOOL)
+	}
+	return false
+}
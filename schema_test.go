@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getSchemaTestLock returns a Locker validating its schema against a table whose "_meta" item
+// is meta, or that has none if meta is empty. It also returns the operations made on "_meta".
+func getSchemaTestLock(meta string) (*Locker, *[]string, func()) {
+	var ops []string
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), metaKey) {
+			fmt.Fprintln(w, "{}")
+			return
+		}
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		ops = append(ops, op)
+		switch {
+		case op == "PutItem" && meta != "" && strings.Contains(string(body), "ConditionExpression"):
+			w.WriteHeader(400)
+			fmt.Fprintln(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`)
+		case op == "GetItem" && meta != "":
+			fmt.Fprintf(w, `{"Item":%s}`, meta)
+		default:
+			fmt.Fprintln(w, "{}")
+		}
+	})
+	lk.ValidateSchema = true
+	return lk, &ops, ts.Close
+}
+
+func TestSchemaCreate(t *testing.T) {
+	lk, ops, done := getSchemaTestLock("")
+	defer done()
+
+	for i := 0; i < 2; i++ {
+		if _, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(*ops, ",") != "PutItem" {
+		t.Errorf("Expected the schema to be written once got %v", *ops)
+	}
+}
+
+// metaItem returns the JSON of a "_meta" item describing s.
+func metaItem(s TableSettings) string {
+	return fmt.Sprintf(`{"schema_version":{"N":"%d"},"timestamp_unit":{"S":"%s"},"table_key":{"S":"%s"},"claims":{"BOOL":%v},"quota":{"BOOL":%v}}`,
+		s.SchemaVersion, s.TimestampUnit, s.TableKey, s.Claims, s.Quota)
+}
+
+func TestSchemaCompatible(t *testing.T) {
+	lk, ops, done := getSchemaTestLock(metaItem(TableSettings{
+		SchemaVersion: SchemaVersion,
+		TimestampUnit: "ms",
+		TableKey:      DefaultTableKey,
+		Claims:        true,
+		Quota:         true,
+	}))
+	defer done()
+	lk.Claims = true
+	// Limits may differ between nodes
+	lk.Quota = &Quota{Limits: map[string]int64{"team": 5}}
+
+	if _, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ops, ",") != "PutItem,GetItem" {
+		t.Errorf("Expected the existing schema to be read got %v", *ops)
+	}
+}
+
+func TestSchemaIncompatible(t *testing.T) {
+	lk, _, done := getSchemaTestLock(metaItem(TableSettings{
+		SchemaVersion: SchemaVersion,
+		TimestampUnit: "ms",
+		TableKey:      DefaultTableKey,
+		Quota:         true,
+	}))
+	defer done()
+
+	locked, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute))
+	if locked || !errors.Is(err, ErrIncompatibleSchema) {
+		t.Errorf("Expected ErrIncompatibleSchema got %v %v", locked, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Quota: false != true") {
+		t.Errorf("Expected the error to describe the difference got %v", err)
+	}
+}
+
+func TestSchemaIncompatibleUnlock(t *testing.T) {
+	lk, ops, done := getSchemaTestLock(metaItem(TableSettings{SchemaVersion: 2}))
+	defer done()
+
+	if err := lk.Unlock(context.Background(), "mylock"); err != nil {
+		t.Errorf("Expected Unlock to ignore the schema got %v", err)
+	}
+	if len(*ops) != 0 {
+		t.Errorf("Expected Unlock not to read the schema got %v", *ops)
+	}
+}
+
+func TestWriteSchema(t *testing.T) {
+	lk, ops, done := getSchemaTestLock(metaItem(TableSettings{SchemaVersion: SchemaVersion, TimestampUnit: "ms", TableKey: DefaultTableKey}))
+	defer done()
+	lk.Claims = true
+
+	if err := lk.WriteSchema(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute)); err != nil {
+		t.Errorf("Expected the rewritten schema to be used got %v", err)
+	}
+	if strings.Join(*ops, ",") != "PutItem" {
+		t.Errorf("Expected a single unconditional write got %v", *ops)
+	}
+}
+
+func TestSchemaDryRun(t *testing.T) {
+	lk, ops, done := getSchemaTestLock("")
+	defer done()
+
+	if _, err := lk.Lock(context.Background(), "mylock", time.Now().Add(10*time.Minute), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ops, ",") != "GetItem" {
+		t.Errorf("Expected a dry run to only read the schema got %v", *ops)
+	}
+}
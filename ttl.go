@@ -0,0 +1,119 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ttlIgnoredAge is how far in the past a TTL can be before DynamoDB stops deleting the item.
+const ttlIgnoredAge = 5 * 365 * 24 * time.Hour
+
+// ttlNeverAge is how far in the future a TTL can be before it is treated as never deleting the
+// item, e.g. because it was written in milliseconds where DynamoDB expects seconds.
+const ttlNeverAge = 100 * 365 * 24 * time.Hour
+
+// TTLReport is the result of VerifyTTL.
+type TTLReport struct {
+	Attribute string // TTL attribute configured on the table, "" if TTL is not enabled
+	Status    string // dynamodb.TimeToLiveStatus of the table
+	// Premature are locks that are still held but that DynamoDB's TTL may delete before
+	// they expire, letting another node acquire them early.
+	Premature []TTLLockInfo
+	// Undeleted are locks that DynamoDB's TTL will never delete because their TTL attribute
+	// is missing, not a number, too far in the past or too far in the future.
+	Undeleted []TTLLockInfo
+}
+
+// TTLLockInfo is a lock entry together with its TTL deletion time.
+// Deletion is zero when the lock will never be deleted by TTL.
+type TTLLockInfo struct {
+	LockInfo
+	Deletion time.Time
+}
+
+// VerifyTTL cross-checks the lease expirations in the lock table against the table's TTL
+// configuration. A lock table doesn't need TTL, since locks are removed by Unlock or taken
+// over once expired, but a TTL configured on the wrong attribute or in the wrong unit either
+// deletes locks that are still held or silently never deletes anything. If TTL is not enabled
+// on the table the report only holds its status.
+func (l *Locker) VerifyTTL(ctx context.Context) (*TTLReport, error) {
+	l.init.Do(l.getState)
+	desc, err := l.state.db.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(l.state.tableName),
+	}, l.requestOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	report := &TTLReport{Status: dynamodb.TimeToLiveStatusDisabled}
+	if d := desc.TimeToLiveDescription; d != nil {
+		report.Status = aws.StringValue(d.TimeToLiveStatus)
+		if report.Status == dynamodb.TimeToLiveStatusEnabled || report.Status == dynamodb.TimeToLiveStatusEnabling {
+			report.Attribute = aws.StringValue(d.AttributeName)
+		}
+	}
+	if report.Attribute == "" {
+		return report, nil
+	}
+
+	req := &dynamodb.ScanInput{
+		FilterExpression:     aws.String(fmt.Sprintf("attribute_exists(%s)", expColumnName)),
+		ProjectionExpression: aws.String(fmt.Sprintf("#key, nodeId, %s", expColumnName)),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(l.state.tableKey),
+		},
+		TableName: aws.String(l.state.tableName),
+	}
+	// Projecting the same attribute twice is an error
+	if report.Attribute != expColumnName {
+		req.ProjectionExpression = aws.String(aws.StringValue(req.ProjectionExpression) + ", #ttl")
+		req.ExpressionAttributeNames["#ttl"] = aws.String(report.Attribute)
+	}
+	now := time.Now()
+	var scanErr error
+	err = l.state.db.ScanPagesWithContext(ctx, req, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			info, err := l.lockInfo(item)
+			if err != nil {
+				scanErr = err
+				return false
+			}
+			lock := TTLLockInfo{LockInfo: info, Deletion: ttlDeletion(item[report.Attribute], now)}
+			switch {
+			case lock.Deletion.IsZero():
+				report.Undeleted = append(report.Undeleted, lock)
+			case lock.Expiration.After(now) && lock.Deletion.Before(lock.Expiration.Truncate(time.Second)):
+				report.Premature = append(report.Premature, lock)
+			}
+		}
+		return true
+	}, l.requestOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return report, nil
+}
+
+// ttlDeletion returns when DynamoDB's TTL will delete an item with the given TTL attribute value,
+// or the zero time if it never will.
+func ttlDeletion(v *dynamodb.AttributeValue, now time.Time) time.Time {
+	if v == nil || v.N == nil {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseInt(*v.N, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	deletion := time.Unix(secs, 0)
+	if deletion.Before(now.Add(-ttlIgnoredAge)) || deletion.After(now.Add(ttlNeverAge)) {
+		return time.Time{}
+	}
+	return deletion
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTTLTestLock(ttlAttr, items string) (*Locker, func()) {
+	lk, ts := getTestLockHandler(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".DescribeTimeToLive") {
+			if ttlAttr == "" {
+				fmt.Fprintln(w, `{"TimeToLiveDescription":{"TimeToLiveStatus":"DISABLED"}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"TimeToLiveDescription":{"AttributeName":"%s","TimeToLiveStatus":"ENABLED"}}`, ttlAttr)
+			return
+		}
+		fmt.Fprintf(w, `{"Items":[%s]}`, items)
+	})
+	return lk, ts.Close
+}
+
+func TestVerifyTTL(t *testing.T) {
+	now := time.Now()
+	exp := millis(now.Add(time.Hour))
+	items := strings.Join([]string{
+		// Deleted at expiration
+		fmt.Sprintf(`{"lock_key":{"S":"ok"},"lease_expiration":{"N":"%d"},"ttl":{"N":"%d"}}`, exp, now.Add(time.Hour).Unix()),
+		// Deleted while held
+		fmt.Sprintf(`{"lock_key":{"S":"early"},"lease_expiration":{"N":"%d"},"ttl":{"N":"%d"}}`, exp, now.Add(time.Minute).Unix()),
+		// TTL written in milliseconds
+		fmt.Sprintf(`{"lock_key":{"S":"millis"},"lease_expiration":{"N":"%d"},"ttl":{"N":"%d"}}`, exp, exp),
+		// No TTL
+		fmt.Sprintf(`{"lock_key":{"S":"missing"},"lease_expiration":{"N":"%d"}}`, exp),
+	}, ",")
+	lk, done := getTTLTestLock("ttl", items)
+	defer done()
+
+	report, err := lk.VerifyTTL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Attribute != "ttl" || report.Status != "ENABLED" {
+		t.Errorf("Unexpected TTL configuration %+v", report)
+	}
+	if len(report.Premature) != 1 || report.Premature[0].Key != "early" {
+		t.Errorf("Expected 'early' to be deleted while held got %+v", report.Premature)
+	}
+	if len(report.Undeleted) != 2 || report.Undeleted[0].Key != "millis" || report.Undeleted[1].Key != "missing" {
+		t.Errorf("Expected 'millis' and 'missing' to never be deleted got %+v", report.Undeleted)
+	}
+}
+
+func TestVerifyTTLDisabled(t *testing.T) {
+	lk, done := getTTLTestLock("", "")
+	defer done()
+
+	report, err := lk.VerifyTTL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Attribute != "" || len(report.Premature) != 0 || len(report.Undeleted) != 0 {
+		t.Errorf("Expected only the status when TTL is disabled got %+v", report)
+	}
+}